@@ -3,19 +3,22 @@ package main
 import (
 	"context"
 	"log"
+	"time"
 
-	"github.com/dielit66/cloud-camp-tt/internal/backend"
 	"github.com/dielit66/cloud-camp-tt/internal/config"
-	"github.com/dielit66/cloud-camp-tt/internal/healthcheck"
 	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/internal/reload"
 	repository "github.com/dielit66/cloud-camp-tt/internal/repository/bucket"
 	"github.com/dielit66/cloud-camp-tt/internal/server"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 )
 
+const configPath = "config.yaml"
+
 func main() {
 	// Подгружаем конфиг из config.yaml
-	cfg, err := config.LoadConfig("config.yaml")
+	cfg, err := config.LoadConfig(configPath)
 
 	if err != nil {
 		log.Fatalf("error while reading config, err: %v", err)
@@ -28,30 +31,49 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	pool := backend.NewPool(cfg.BackendPool.URLs, logger)
-
-	// Инициализируем health checker для периодической проверки состояния бэкендов
-	healthChecker := healthcheck.NewHealthChecker(
-		cfg.BackendPool.HealthCheck.Endpoint,
-		cfg.BackendPool.HealthCheck.Timeout,
-		logger,
-	)
+	// Настраиваем propagation W3C traceparent и экспорт span'ов по OTLP
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logger.Fatal("failed to set up tracing", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Error("failed to flush traces", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}()
 
 	// Инициализируем rate limiter для ограничения частоты запросов клиентов
 	repo := repository.NewRedisBucketSettingsRepository(logger, &cfg.RateLimiter)
-	rl := ratelimiter.NewRateLimiter(ctx, repo, logger, &cfg.RateLimiter)
+	// В распределенном режиме buckets хранятся в Redis и общие для всех экземпляров балансировщика
+	var store ratelimiter.IBucketStore
+	if cfg.RateLimiter.Distributed.Enabled {
+		store = repo.BucketStore()
+	}
+	rl := ratelimiter.NewRateLimiter(ctx, repo, store, logger, &cfg.RateLimiter)
+
+	// Создаем пулы бэкендов (пул по умолчанию и именованные) с активными и пассивными проверками.
+	// Бэкенды проверяются до старта сервера, чтобы не отправлять трафик на непроверенные
+	upstreams := make(map[string]*server.Upstream)
+	for name, poolCfg := range cfg.AllPools() {
+		upstream := server.NewUpstream(name, poolCfg, logger)
+		upstream.Start(ctx)
+		upstreams[name] = upstream
+	}
 
-	// Запускаем health checker в отдельной горутине для периодической проверки бэкендов
-	go healthChecker.Start(
-		ctx,
-		pool.Backends,
-		cfg.BackendPool.HealthCheck.Timeout,
-	)
+	lb := server.NewLoadBalancer(upstreams, logger, rl, repo)
 
-	lb := server.NewLoadBalancer(pool, logger, rl, repo)
+	// Перечитываем конфиг по SIGHUP (и при изменении файла, если включен reload.watch)
+	reloader := reload.NewReloader(configPath, cfg, lb, rl, logger)
+	go reloader.Start(ctx)
 
 	// Запускаем HTTP-сервер load balancer'а
-	if err = lb.StartServer(&cfg.Server); err != nil {
+	if err = lb.StartServer(cfg); err != nil {
 		logger.Fatal(err.Error(), nil)
 	}
 }
@@ -0,0 +1,287 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Entry собирает сведения о запросе, которые известны только внутренним обработчикам:
+// решение rate limiter и бэкенды, на которые проксировался запрос
+type Entry struct {
+	Upstream        string
+	UpstreamLatency time.Duration
+	RateLimit       string
+}
+
+type entryKey struct{}
+
+// FromContext возвращает Entry текущего запроса или nil, если access log выключен.
+// Методы Entry можно вызывать и на nil
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(entryKey{}).(*Entry)
+	return e
+}
+
+// SetUpstream добавляет попытку проксирования; при повторах адреса перечисляются через запятую,
+// а латентность суммируется
+func (e *Entry) SetUpstream(addr string, latency time.Duration) {
+	if e == nil {
+		return
+	}
+	if e.Upstream != "" {
+		e.Upstream += ", "
+	}
+	e.Upstream += addr
+	e.UpstreamLatency += latency
+}
+
+// SetRateLimit запоминает решение rate limiter
+func (e *Entry) SetRateLimit(decision string) {
+	if e == nil {
+		return
+	}
+	e.RateLimit = decision
+}
+
+// Logger пишет одну строку на каждый запрос в формате json, combined или по шаблону
+type Logger struct {
+	cfg config.AccessLog
+	// json - логгер для format: json, пишет в out
+	json logging.ILogger
+	// out - writer журнала (файл или stdout)
+	out      io.Writer
+	mux      sync.Mutex
+	closer   io.Closer
+	clientIP func(*http.Request) string
+}
+
+// New создает access log. Если задан file, журнал пишется в файл с ротацией по размеру, иначе в stdout
+func New(cfg config.AccessLog, clientIP func(*http.Request) string) *Logger {
+	al := &Logger{
+		cfg:      cfg,
+		out:      os.Stdout,
+		clientIP: clientIP,
+	}
+
+	if cfg.File != "" {
+		rotator := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+		}
+		al.out = rotator
+		al.closer = rotator
+	}
+	// Отдельный логгер уровня Info, чтобы журнал не зависел от logger_level основного логгера
+	al.json = logging.NewZeroLoggerWithWriter(1, al.out)
+
+	return al
+}
+
+// Close закрывает файл журнала
+func (al *Logger) Close() error {
+	if al.closer == nil {
+		return nil
+	}
+	return al.closer.Close()
+}
+
+// Middleware записывает строку журнала после завершения запроса.
+// Должен стоять после WithRequestID и перед rate limiter, чтобы видеть его решение
+func (al *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &Entry{}
+		rec := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), entryKey{}, entry)))
+
+		if rec.status < http.StatusBadRequest && !al.sample() {
+			return
+		}
+		al.write(al.record(r, rec, entry, start))
+	})
+}
+
+// sample решает, записывать ли успешный запрос
+func (al *Logger) sample() bool {
+	return al.cfg.SuccessSampleRate >= 1 || rand.Float64() < al.cfg.SuccessSampleRate
+}
+
+// record - поля строки журнала
+type record struct {
+	time            time.Time
+	clientIP        string
+	method          string
+	uri             string
+	proto           string
+	host            string
+	status          int
+	bytes           int64
+	upstream        string
+	upstreamLatency time.Duration
+	latency         time.Duration
+	requestID       string
+	rateLimit       string
+	userAgent       string
+	referer         string
+}
+
+func (al *Logger) record(r *http.Request, rec *responseWriter, entry *Entry, start time.Time) record {
+	requestID, _ := r.Context().Value(middleware.RequestIDKey).(string)
+
+	return record{
+		time:            start,
+		clientIP:        al.clientIP(r),
+		method:          r.Method,
+		uri:             r.RequestURI,
+		proto:           r.Proto,
+		host:            r.Host,
+		status:          rec.status,
+		bytes:           rec.bytes,
+		upstream:        entry.Upstream,
+		upstreamLatency: entry.UpstreamLatency,
+		latency:         time.Since(start),
+		requestID:       requestID,
+		rateLimit:       entry.RateLimit,
+		userAgent:       r.UserAgent(),
+		referer:         r.Referer(),
+	}
+}
+
+func (al *Logger) write(rc record) {
+	switch al.cfg.Format {
+	case config.AccessLogCombined:
+		al.writeLine(combined(rc))
+	case config.AccessLogTemplate:
+		al.writeLine(os.Expand(al.cfg.Template, rc.variable))
+	default:
+		al.json.Info("access", map[string]interface{}{
+			"client_ip":           rc.clientIP,
+			"method":              rc.method,
+			"uri":                 rc.uri,
+			"status":              rc.status,
+			"bytes":               rc.bytes,
+			"upstream":            rc.upstream,
+			"upstream_latency_ms": milliseconds(rc.upstreamLatency),
+			"latency_ms":          milliseconds(rc.latency),
+			"request_id":          rc.requestID,
+			"rate_limit":          rc.rateLimit,
+			"user_agent":          rc.userAgent,
+			"time":                rc.time.Format(time.RFC3339),
+		})
+	}
+}
+
+func (al *Logger) writeLine(line string) {
+	al.mux.Lock()
+	defer al.mux.Unlock()
+
+	io.WriteString(al.out, line+"\n")
+}
+
+// combined форматирует строку в формате Apache combined:
+// %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func combined(rc record) string {
+	bytes := "-"
+	if rc.bytes > 0 {
+		bytes = strconv.FormatInt(rc.bytes, 10)
+	}
+
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s"`,
+		rc.clientIP,
+		rc.time.Format("02/Jan/2006:15:04:05 -0700"),
+		rc.method, rc.uri, rc.proto,
+		rc.status, bytes,
+		dash(rc.referer), dash(rc.userAgent),
+	)
+}
+
+// variable возвращает значение переменной шаблона; неизвестные переменные заменяются на "-"
+func (rc record) variable(name string) string {
+	switch name {
+	case "time":
+		return rc.time.Format(time.RFC3339)
+	case "client_ip":
+		return rc.clientIP
+	case "method":
+		return rc.method
+	case "uri":
+		return rc.uri
+	case "proto":
+		return rc.proto
+	case "host":
+		return rc.host
+	case "status":
+		return strconv.Itoa(rc.status)
+	case "bytes":
+		return strconv.FormatInt(rc.bytes, 10)
+	case "upstream":
+		return dash(rc.upstream)
+	case "upstream_latency":
+		return strconv.FormatFloat(milliseconds(rc.upstreamLatency), 'f', 3, 64)
+	case "latency":
+		return strconv.FormatFloat(milliseconds(rc.latency), 'f', 3, 64)
+	case "request_id":
+		return dash(rc.requestID)
+	case "rate_limit":
+		return dash(rc.rateLimit)
+	case "user_agent":
+		return dash(rc.userAgent)
+	case "referer":
+		return dash(rc.referer)
+	default:
+		return "-"
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// responseWriter запоминает код ответа и число отправленных байт
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
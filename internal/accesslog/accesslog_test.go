@@ -0,0 +1,77 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func clientIP(r *http.Request) string { return "203.0.113.7" }
+
+// serve пропускает запросы со статусами statuses через access log и возвращает строки журнала
+func serve(t *testing.T, cfg config.AccessLog, statuses ...int) []string {
+	t.Helper()
+
+	cfg.File = filepath.Join(t.TempDir(), "access.log")
+	al := New(cfg, clientIP)
+	for _, status := range statuses {
+		h := al.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestJSONAtInfoLevel(t *testing.T) {
+	lines := serve(t, config.AccessLog{Format: config.AccessLogJSON, SuccessSampleRate: 1}, http.StatusOK)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("line is not json: %v", err)
+	}
+	if entry["level"] != "info" || entry["message"] != "access" || entry["client_ip"] != "203.0.113.7" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+}
+
+func TestSuccessSampleRate(t *testing.T) {
+	tests := []struct {
+		name string
+		rate float64
+		want int
+	}{
+		{name: "all", rate: 1, want: 4},
+		{name: "errors only", rate: 0, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.AccessLog{Format: config.AccessLogJSON, SuccessSampleRate: tt.rate}
+			lines := serve(t, cfg, http.StatusOK, http.StatusNotFound, http.StatusNoContent, http.StatusBadGateway)
+			if len(lines) != tt.want {
+				t.Fatalf("got %d lines, want %d", len(lines), tt.want)
+			}
+		})
+	}
+}
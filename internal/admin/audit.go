@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// maxAuditBody - сколько байт тела запроса попадает в audit log
+const maxAuditBody = 4 << 10
+
+// Auditor пишет в audit log каждый изменяющий запрос к admin API, в том числе отклоненный
+type Auditor struct {
+	logger logging.ILogger
+	closer io.Closer
+}
+
+// NewAuditor создает audit log. Если задан file, журнал пишется в файл с ротацией, иначе - через l
+func NewAuditor(file string, l logging.ILogger) *Auditor {
+	if file == "" {
+		return &Auditor{logger: l}
+	}
+
+	rotator := &lumberjack.Logger{Filename: file}
+	return &Auditor{
+		// Уровень Info, чтобы журнал не зависел от logger_level основного логгера
+		logger: logging.NewZeroLoggerWithWriter(1, rotator),
+		closer: rotator,
+	}
+}
+
+// Close закрывает файл журнала
+func (au *Auditor) Close() error {
+	if au.closer == nil {
+		return nil
+	}
+	return au.closer.Close()
+}
+
+// auditEntry - клиент запроса; заполняется Authenticator.Middleware
+type auditEntry struct {
+	principal Principal
+}
+
+type entryKey struct{}
+
+func entryFromContext(ctx context.Context) *auditEntry {
+	e, _ := ctx.Value(entryKey{}).(*auditEntry)
+	return e
+}
+
+// Middleware записывает изменяющие запросы после их выполнения. Должен стоять перед
+// Authenticator.Middleware, чтобы в журнал попадали и отклоненные попытки
+func (au *Auditor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body := readBody(r)
+		entry := &auditEntry{}
+		rec := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), entryKey{}, entry)))
+
+		requestID, _ := r.Context().Value(middleware.RequestIDKey).(string)
+		au.logger.Info("audit", map[string]interface{}{
+			"principal":   entry.principal.Name,
+			"role":        entry.principal.Role,
+			"remote_addr": r.RemoteAddr,
+			"method":      r.Method,
+			"uri":         r.RequestURI,
+			"body":        body,
+			"status":      rec.status,
+			"request_id":  requestID,
+			"time":        time.Now().Format(time.RFC3339),
+		})
+	})
+}
+
+// readBody возвращает начало тела запроса для журнала, не забирая его у обработчика
+func readBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	head, _ := io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+
+	return string(head)
+}
+
+// statusWriter запоминает код ответа для журнала
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
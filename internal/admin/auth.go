@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/errors"
+)
+
+// Principal - аутентифицированный клиент admin API
+type Principal struct {
+	Name string
+	Role string
+}
+
+// Authenticator проверяет bearer-токены и клиентские сертификаты admin API
+type Authenticator struct {
+	tokens []config.AdminToken
+	// clients - роли клиентов mTLS по Common Name
+	clients map[string]string
+}
+
+func NewAuthenticator(cfg config.Admin) *Authenticator {
+	clients := make(map[string]string, len(cfg.TLS.Clients))
+	for _, cl := range cfg.TLS.Clients {
+		clients[cl.CommonName] = cl.Role
+	}
+
+	return &Authenticator{
+		tokens:  cfg.Tokens,
+		clients: clients,
+	}
+}
+
+// Authenticate определяет клиента по проверенному сертификату или заголовку Authorization: Bearer
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, bool) {
+	// Цепочка сертификата уже проверена TLS-сервером по client_ca_file
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if role, ok := a.clients[cn]; ok {
+			return Principal{Name: "cert:" + cn, Role: role}, true
+		}
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, false
+	}
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return Principal{Name: t.Name, Role: t.Role}, true
+		}
+	}
+	return Principal{}, false
+}
+
+// Allowed сообщает, разрешен ли клиенту запрос: роль read допускает только чтение
+func (p Principal) Allowed(r *http.Request) bool {
+	return p.Role == config.AdminRoleWrite || isReadOnly(r)
+}
+
+func isReadOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// Middleware пропускает к admin API только аутентифицированных клиентов с подходящей ролью
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeAPIError(w, errors.NewAPIError(http.StatusUnauthorized, "Unauthorized"))
+			return
+		}
+
+		if entry := entryFromContext(r.Context()); entry != nil {
+			entry.principal = p
+		}
+
+		if !p.Allowed(r) {
+			writeAPIError(w, errors.NewAPIError(http.StatusForbidden, "Forbidden"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeAPIError(w http.ResponseWriter, err *errors.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	w.Write(err.ToJSON())
+}
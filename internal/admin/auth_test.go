@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func TestAuthenticatorMiddleware(t *testing.T) {
+	auth := NewAuthenticator(config.Admin{Tokens: []config.AdminToken{
+		{Name: "ops", Token: "write-token", Role: config.AdminRoleWrite},
+		{Name: "dashboard", Token: "read-token", Role: config.AdminRoleRead},
+	}})
+	h := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		want   int
+	}{
+		{name: "no token", method: http.MethodGet, want: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "not bearer", method: http.MethodGet, header: "Basic cmVhZC10b2tlbg==", want: http.StatusUnauthorized},
+		{name: "read role get", method: http.MethodGet, header: "Bearer read-token", want: http.StatusOK},
+		{name: "read role post", method: http.MethodPost, header: "Bearer read-token", want: http.StatusForbidden},
+		{name: "write role post", method: http.MethodPost, header: "Bearer write-token", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/status", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+const unixPrefix = "unix:"
+
+// Listen открывает отдельный listener admin API: tcp (host:port) или unix-сокет (unix:/path).
+// Если задан tls, соединения принимаются только по TLS
+func Listen(cfg config.Admin) (net.Listener, error) {
+	ln, err := listen(cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TLS.CertFile == "" {
+		return ln, nil
+	}
+
+	tlsCfg, err := newTLSConfig(cfg)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return tls.NewListener(ln, tlsCfg), nil
+}
+
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	// Сокет мог остаться от предыдущего запуска, завершившегося аварийно
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Доступ к сокету - только у владельца и группы
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func newTLSConfig(cfg config.Admin) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("admin.tls: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLS.ClientCAFile == "" {
+		return tlsCfg, nil
+	}
+
+	pem, err := os.ReadFile(cfg.TLS.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("admin.tls.client_ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("admin.tls.client_ca_file: no certificates found")
+	}
+	tlsCfg.ClientCAs = pool
+
+	// Если есть токены, сертификат не обязателен: клиент может аутентифицироваться любым способом
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	if len(cfg.Tokens) > 0 {
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// AddBackendRequest represents the API request to add a backend to the pool
+type AddBackendRequest struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Validate validates the request
+func (r *AddBackendRequest) Validate() error {
+	if r.URL == "" {
+		return errors.New("url is required")
+	}
+	if r.Weight < 0 {
+		return errors.New("weight must not be negative")
+	}
+	return nil
+}
+
+// BackendResponse represents the API response
+type BackendResponse struct {
+	URL               string `json:"url"`
+	Weight            int    `json:"weight"`
+	Alive             bool   `json:"alive"`
+	Enabled           bool   `json:"enabled"`
+	Ejected           bool   `json:"ejected"`
+	Breaker           string `json:"circuit_breaker"`
+	ActiveConnections int32  `json:"active_connections"`
+}
+
+// NewBackendResponse builds the API representation of a backend
+func NewBackendResponse(b *Backend) BackendResponse {
+	return BackendResponse{
+		URL:               b.URL.String(),
+		Weight:            b.Weight,
+		Alive:             b.IsAlive(),
+		Enabled:           b.IsEnabled(),
+		Ejected:           b.IsEjected(),
+		Breaker:           string(b.Breaker.State()),
+		ActiveConnections: atomic.LoadInt32(&b.ActiveConnections),
+	}
+}
@@ -1,18 +1,48 @@
 package backend
 
 import (
+	"math"
 	"net/http/httputil"
 	"net/url"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+const (
+	// ewmaDecay - время, за которое старые замеры латентности теряют вес (peak EWMA)
+	ewmaDecay = 10 * time.Second
+	// failurePenalty - латентность, которая засчитывается неудачному запросу: быстрый отказ
+	// (connection refused, мгновенный 5xx) не должен делать бэкенд привлекательнее для EWMA
+	failurePenalty = 5 * time.Second
 )
 
 type Backend struct {
 	URL               *url.URL
 	Proxy             *httputil.ReverseProxy
 	alive             bool
+	disabled          bool
+	ejectedUntil      time.Time
 	mux               sync.RWMutex
 	ActiveConnections int32
+	Weight            int
+	// Breaker - circuit breaker бэкенда по результатам проксируемых запросов
+	Breaker *CircuitBreaker
+
+	// healthCheck - переопределение глобальных настроек healthcheck для этого бэкенда (может быть nil)
+	healthCheck *config.HealthCheck
+	// timeouts - переопределение таймаутов соединения из конфигурации (может быть nil)
+	timeouts *config.UpstreamTimeouts
+
+	latencyMux  sync.Mutex
+	ewma        float64 // в наносекундах
+	lastLatency time.Time
+
+	// requests и errors - число проксированных на бэкенд запросов и неудачных из них
+	requests atomic.Int64
+	errors   atomic.Int64
 }
 
 func (b *Backend) SetAlive(isAlive bool) {
@@ -29,9 +59,117 @@ func (b *Backend) IsAlive() bool {
 	return b.alive
 }
 
+func (b *Backend) SetEnabled(isEnabled bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.disabled = !isEnabled
+}
+
+func (b *Backend) IsEnabled() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	return !b.disabled
+}
+
+// Eject временно исключает бэкенд из балансировки (пассивная проверка по трафику)
+func (b *Backend) Eject(until time.Time) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.ejectedUntil = until
+}
+
+func (b *Backend) IsEjected() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	return time.Now().Before(b.ejectedUntil)
+}
+
+// IsAvailable сообщает, может ли бэкенд принимать новые запросы: он жив, не отключен
+// через admin API, не исключен outlier detection и его circuit breaker пропускает запросы
+func (b *Backend) IsAvailable() bool {
+	b.mux.RLock()
+	available := b.alive && !b.disabled && !time.Now().Before(b.ejectedUntil)
+	b.mux.RUnlock()
+
+	return available && (b.Breaker == nil || b.Breaker.Ready())
+}
+
+func (b *Backend) SetHealthCheck(hc *config.HealthCheck) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.healthCheck = hc
+}
+
+// HealthCheck возвращает переопределение настроек healthcheck или nil
+func (b *Backend) HealthCheck() *config.HealthCheck {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	return b.healthCheck
+}
+
 func (b *Backend) AddConnection() {
 	atomic.AddInt32(&b.ActiveConnections, 1)
 }
 func (b *Backend) ConnectionDone() {
 	atomic.AddInt32(&b.ActiveConnections, -1)
 }
+
+// ObserveRequest учитывает завершенный запрос в счетчиках бэкенда
+func (b *Backend) ObserveRequest(success bool) {
+	b.requests.Add(1)
+	if !success {
+		b.errors.Add(1)
+	}
+}
+
+// Requests возвращает число запросов к бэкенду и число неудачных из них
+func (b *Backend) Requests() (total, errors int64) {
+	return b.requests.Load(), b.errors.Load()
+}
+
+// ObserveLatency обновляет peak EWMA латентности бэкенда: рост учитывается сразу,
+// а снижение сглаживается экспоненциально в зависимости от времени с прошлого замера
+func (b *Backend) ObserveLatency(d time.Duration) {
+	b.latencyMux.Lock()
+	defer b.latencyMux.Unlock()
+
+	now := time.Now()
+	sample := float64(d)
+
+	if sample > b.ewma || b.lastLatency.IsZero() {
+		b.ewma = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(b.lastLatency)) / float64(ewmaDecay))
+		b.ewma = b.ewma*w + sample*(1-w)
+	}
+	b.lastLatency = now
+}
+
+// ObserveFailure засчитывает неудачному запросу латентность failurePenalty
+func (b *Backend) ObserveFailure() {
+	b.ObserveLatency(failurePenalty)
+}
+
+// Latency возвращает текущее значение EWMA латентности. Без новых замеров оценка затухает,
+// чтобы бэкенд со штрафом за ошибки со временем снова получил запрос и мог восстановиться
+func (b *Backend) Latency() time.Duration {
+	b.latencyMux.Lock()
+	defer b.latencyMux.Unlock()
+
+	if b.lastLatency.IsZero() {
+		return 0
+	}
+	return time.Duration(b.ewma * math.Exp(-float64(time.Since(b.lastLatency))/float64(ewmaDecay)))
+}
+
+// Load - оценка нагрузки для peak EWMA: латентность * (активные запросы + 1)
+func (b *Backend) Load() float64 {
+	inflight := float64(atomic.LoadInt32(&b.ActiveConnections)) + 1
+	return float64(b.Latency()) * inflight
+}
@@ -0,0 +1,258 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/clock"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// BreakerState - состояние circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// breakerBuckets - на сколько частей делится скользящее окно
+const breakerBuckets = 10
+
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// CircuitBreaker открывается, когда доля ошибок в скользящем окне превышает порог.
+// Пока он открыт, балансировщики пропускают бэкенд; после cooldown breaker переходит
+// в half-open и пропускает ограниченное число пробных запросов.
+type CircuitBreaker struct {
+	name   string
+	cfg    config.CircuitBreaker
+	logger logging.ILogger
+
+	clock clock.Clock
+
+	mux      sync.Mutex
+	state    BreakerState
+	openedAt time.Time
+	buckets  [breakerBuckets]breakerBucket
+	// generation увеличивается при каждой смене состояния, чтобы результаты запросов,
+	// начатых до нее, не учитывались в новом состоянии
+	generation uint64
+	// trials и trialSuccesses считают пробные запросы в half-open
+	trials         int
+	trialSuccesses int
+}
+
+// Permit - разрешение circuit breaker на один запрос. Выдается TryAcquire, результат запроса передается в Done
+type Permit struct {
+	generation uint64
+	// trial - запрос занял пробный слот half-open
+	trial bool
+}
+
+// BreakerOption - необязательная настройка circuit breaker
+type BreakerOption func(*CircuitBreaker)
+
+// WithClock задает источник времени вместо системных часов, например фиксированное время в тестах
+func WithClock(c clock.Clock) BreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.clock = c
+	}
+}
+
+func NewCircuitBreaker(name string, cfg config.CircuitBreaker, l logging.ILogger, opts ...BreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:   name,
+		cfg:    cfg,
+		logger: l,
+		clock:  clock.System{},
+		state:  BreakerClosed,
+	}
+
+	for _, opt := range opts {
+		opt(cb)
+	}
+
+	return cb
+}
+
+// Update применяет новые настройки; при выключении breaker закрывается
+func (cb *CircuitBreaker) Update(cfg config.CircuitBreaker) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	cb.cfg = cfg
+	if !cfg.Enabled && cb.state != BreakerClosed {
+		cb.setState(BreakerClosed, cb.clock.Now())
+	}
+}
+
+// State возвращает текущее состояние с учетом истекшего cooldown
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	cb.advance(cb.clock.Now())
+	return cb.state
+}
+
+// Ready сообщает, можно ли отправить на бэкенд новый запрос. Не резервирует пробный слот,
+// поэтому балансировщики используют его только для выбора; перед отправкой нужен TryAcquire
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	if !cb.cfg.Enabled {
+		return true
+	}
+
+	cb.advance(cb.clock.Now())
+
+	switch cb.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		return cb.trials < cb.cfg.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+// TryAcquire проверяет состояние и в том же вызове резервирует место для запроса:
+// в half-open занимает пробный слот, если он свободен. false - запрос на бэкенд отправлять нельзя
+func (cb *CircuitBreaker) TryAcquire() (Permit, bool) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	if !cb.cfg.Enabled {
+		return Permit{generation: cb.generation}, true
+	}
+
+	cb.advance(cb.clock.Now())
+
+	switch cb.state {
+	case BreakerOpen:
+		return Permit{}, false
+	case BreakerHalfOpen:
+		if cb.trials >= cb.cfg.HalfOpenRequests {
+			return Permit{}, false
+		}
+		cb.trials++
+		return Permit{generation: cb.generation, trial: true}, true
+	default:
+		return Permit{generation: cb.generation}, true
+	}
+}
+
+// Done учитывает результат запроса, получившего p: success=false для ошибок соединения и ответов 5xx.
+// Результаты запросов, начатых до смены состояния, не учитываются: в half-open решение принимается
+// только по пробным запросам
+func (cb *CircuitBreaker) Done(p Permit, success bool) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	if !cb.cfg.Enabled || p.generation != cb.generation {
+		return
+	}
+
+	now := cb.clock.Now()
+	cb.advance(now)
+	if p.generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case BreakerHalfOpen:
+		if !p.trial {
+			return
+		}
+		if !success {
+			cb.setState(BreakerOpen, now)
+			return
+		}
+		cb.trialSuccesses++
+		if cb.trialSuccesses >= cb.cfg.HalfOpenRequests {
+			cb.setState(BreakerClosed, now)
+		}
+	case BreakerClosed:
+		b := cb.bucket(now)
+		b.requests++
+		if !success {
+			b.failures++
+		}
+
+		requests, failures := cb.totals(now)
+		if requests >= cb.cfg.MinRequests && float64(failures)/float64(requests) >= cb.cfg.ErrorRatio {
+			cb.setState(BreakerOpen, now)
+		}
+	}
+}
+
+// advance переводит открытый breaker в half-open, когда истек cooldown
+func (cb *CircuitBreaker) advance(now time.Time) {
+	if cb.state == BreakerOpen && now.Sub(cb.openedAt) >= cb.cfg.Cooldown {
+		cb.setState(BreakerHalfOpen, now)
+	}
+}
+
+func (cb *CircuitBreaker) setState(state BreakerState, now time.Time) {
+	prev := cb.state
+	cb.state = state
+	cb.generation++
+	cb.trials, cb.trialSuccesses = 0, 0
+
+	switch state {
+	case BreakerOpen:
+		cb.openedAt = now
+	case BreakerClosed:
+		cb.buckets = [breakerBuckets]breakerBucket{}
+	}
+
+	fields := map[string]interface{}{
+		"url":  cb.name,
+		"from": string(prev),
+		"to":   string(state),
+	}
+	if state == BreakerOpen {
+		cb.logger.Warn("Circuit breaker state changed", fields)
+	} else {
+		cb.logger.Info("Circuit breaker state changed", fields)
+	}
+}
+
+// bucket возвращает корзину окна для текущего момента, обнуляя ее, если она устарела
+func (cb *CircuitBreaker) bucket(now time.Time) *breakerBucket {
+	size := cb.bucketSize()
+	start := now.Truncate(size)
+	b := &cb.buckets[(start.UnixNano()/int64(size))%breakerBuckets]
+
+	if !b.start.Equal(start) {
+		*b = breakerBucket{start: start}
+	}
+
+	return b
+}
+
+func (cb *CircuitBreaker) totals(now time.Time) (requests, failures int) {
+	for _, b := range cb.buckets {
+		if now.Sub(b.start) < cb.cfg.Window {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+	return requests, failures
+}
+
+func (cb *CircuitBreaker) bucketSize() time.Duration {
+	size := cb.cfg.Window / breakerBuckets
+	if size <= 0 {
+		size = time.Millisecond
+	}
+	return size
+}
@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/clock/clocktest"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+var testBreakerConfig = config.CircuitBreaker{
+	Enabled:          true,
+	ErrorRatio:       0.5,
+	MinRequests:      4,
+	Window:           10 * time.Second,
+	Cooldown:         30 * time.Second,
+	HalfOpenRequests: 2,
+}
+
+func newTestBreaker(cfg config.CircuitBreaker) (*CircuitBreaker, *clocktest.Fake) {
+	clock := clocktest.NewFake()
+	cb := NewCircuitBreaker("http://backend", cfg, logging.NewZeroLoggerWithWriter(3, io.Discard), WithClock(clock))
+	return cb, clock
+}
+
+// request выполняет запрос через breaker; false, если breaker его не пропустил
+func request(cb *CircuitBreaker, success bool) bool {
+	p, ok := cb.TryAcquire()
+	if ok {
+		cb.Done(p, success)
+	}
+	return ok
+}
+
+func expectState(t *testing.T, cb *CircuitBreaker, want BreakerState) {
+	t.Helper()
+	if got := cb.State(); got != want {
+		t.Fatalf("State() = %q, want %q", got, want)
+	}
+}
+
+// trip открывает breaker ошибками
+func trip(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < testBreakerConfig.MinRequests; i++ {
+		request(cb, false)
+	}
+	expectState(t, cb, BreakerOpen)
+}
+
+func TestBreakerOpensOnErrorRatio(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+
+	// 3 запроса меньше min_requests: доля ошибок еще не учитывается
+	request(cb, false)
+	request(cb, false)
+	request(cb, true)
+	expectState(t, cb, BreakerClosed)
+
+	clock.Advance(time.Second)
+	request(cb, true)
+	// 2 ошибки из 4 - ровно error_ratio
+	expectState(t, cb, BreakerOpen)
+
+	if cb.Ready() {
+		t.Fatal("Ready() = true for open breaker")
+	}
+	if request(cb, true) {
+		t.Fatal("open breaker let a request through")
+	}
+}
+
+func TestBreakerWindowExpires(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+
+	request(cb, false)
+	request(cb, false)
+	request(cb, false)
+
+	// Ошибки вышли из окна, новые запросы успешны
+	clock.Advance(testBreakerConfig.Window + time.Second)
+	for i := 0; i < 4; i++ {
+		request(cb, true)
+	}
+	expectState(t, cb, BreakerClosed)
+}
+
+func TestBreakerHalfOpenCloses(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+	trip(t, cb)
+
+	clock.Advance(testBreakerConfig.Cooldown - time.Millisecond)
+	expectState(t, cb, BreakerOpen)
+
+	clock.Advance(time.Millisecond)
+	expectState(t, cb, BreakerHalfOpen)
+
+	p1, ok1 := cb.TryAcquire()
+	p2, ok2 := cb.TryAcquire()
+	if !ok1 || !ok2 {
+		t.Fatal("half-open breaker did not grant half_open_requests trial slots")
+	}
+	if _, ok := cb.TryAcquire(); ok {
+		t.Fatal("half-open breaker granted more than half_open_requests trial slots")
+	}
+	if cb.Ready() {
+		t.Fatal("Ready() = true with all trial slots taken")
+	}
+
+	cb.Done(p1, true)
+	expectState(t, cb, BreakerHalfOpen)
+	cb.Done(p2, true)
+	expectState(t, cb, BreakerClosed)
+
+	if !request(cb, true) {
+		t.Fatal("closed breaker rejected a request")
+	}
+}
+
+func TestBreakerHalfOpenReopens(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+	trip(t, cb)
+
+	clock.Advance(testBreakerConfig.Cooldown)
+	p1, _ := cb.TryAcquire()
+	p2, _ := cb.TryAcquire()
+
+	cb.Done(p1, false)
+	expectState(t, cb, BreakerOpen)
+
+	// Успех второго пробного запроса после повторного открытия ничего не меняет
+	cb.Done(p2, true)
+	expectState(t, cb, BreakerOpen)
+
+	// Cooldown отсчитывается заново от повторного открытия
+	clock.Advance(testBreakerConfig.Cooldown - time.Millisecond)
+	expectState(t, cb, BreakerOpen)
+	clock.Advance(time.Millisecond)
+	expectState(t, cb, BreakerHalfOpen)
+}
+
+func TestBreakerIgnoresRequestsStartedBeforeTrip(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+
+	// Медленный запрос начат, пока breaker был закрыт
+	slow, ok := cb.TryAcquire()
+	if !ok {
+		t.Fatal("closed breaker rejected a request")
+	}
+
+	trip(t, cb)
+	clock.Advance(testBreakerConfig.Cooldown)
+	expectState(t, cb, BreakerHalfOpen)
+
+	// Его успех не пробный запрос и не должен закрывать breaker
+	cb.Done(slow, true)
+	cb.Done(slow, true)
+	expectState(t, cb, BreakerHalfOpen)
+
+	if !request(cb, true) || !request(cb, true) {
+		t.Fatal("half-open breaker rejected trial requests")
+	}
+	expectState(t, cb, BreakerClosed)
+}
+
+func TestBreakerTrialSlotsConcurrent(t *testing.T) {
+	cb, clock := newTestBreaker(testBreakerConfig)
+	trip(t, cb)
+	clock.Advance(testBreakerConfig.Cooldown)
+
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if cb.Ready() {
+				if _, ok := cb.TryAcquire(); ok {
+					granted.Add(1)
+				}
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := int(granted.Load()); got != testBreakerConfig.HalfOpenRequests {
+		t.Fatalf("granted %d trial slots, want %d", got, testBreakerConfig.HalfOpenRequests)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	cfg := testBreakerConfig
+	cfg.Enabled = false
+	cb, _ := newTestBreaker(cfg)
+
+	for i := 0; i < 10; i++ {
+		if !request(cb, false) {
+			t.Fatal("disabled breaker rejected a request")
+		}
+	}
+	expectState(t, cb, BreakerClosed)
+
+	// Выключение через Update закрывает открытый breaker
+	cb, _ = newTestBreaker(testBreakerConfig)
+	trip(t, cb)
+	cb.Update(cfg)
+	expectState(t, cb, BreakerClosed)
+	if !request(cb, true) {
+		t.Fatal("breaker rejected a request after it was disabled")
+	}
+}
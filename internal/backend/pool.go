@@ -1,77 +1,298 @@
 package backend
 
 import (
-	"net/http/httputil"
+	"errors"
 	"net/url"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 )
 
+var (
+	ErrBackendExists   = errors.New("backend already exists")
+	ErrBackendNotFound = errors.New("backend not found")
+	ErrInvalidURL      = errors.New("url must be absolute http(s) url")
+)
+
+// drainCheckInterval - как часто проверяем, завершились ли запросы удаляемого бэкенда
+const drainCheckInterval = 100 * time.Millisecond
+
+// Pool хранит список бэкендов. Список меняется только через методы пула,
+// поэтому health checker и балансировщики читают его через List()
 type Pool struct {
-	Backends []*Backend
-	current  uint
-	mux      sync.RWMutex
-	logger   logging.ILogger
+	// name - имя пула для меток метрик
+	name       string
+	backends   []*Backend
+	breakerCfg config.CircuitBreaker
+	timeouts   config.UpstreamTimeouts
+	mux        sync.RWMutex
+	logger     logging.ILogger
 }
 
-func NewPool(urls []string, l logging.ILogger) *Pool {
-	var pool Pool
+func NewPool(name string, cfg config.BackendPool, l logging.ILogger) *Pool {
+	pool := &Pool{
+		name:       name,
+		breakerCfg: cfg.CircuitBreaker,
+		timeouts:   cfg.Timeouts,
+		logger:     l,
+	}
 
-	for _, u := range urls {
-		parsedUrl, _ := url.Parse(u)
-		pool.Backends = append(pool.Backends, &Backend{
-			URL:               parsedUrl,
-			Proxy:             httputil.NewSingleHostReverseProxy(parsedUrl),
-			alive:             true,
-			ActiveConnections: 0,
-		})
+	for _, e := range cfg.Entries() {
+		b, err := pool.newBackend(e, true)
+		if err != nil {
+			l.Error("Skipping invalid backend", map[string]interface{}{
+				"url":   e.URL,
+				"error": err.Error(),
+			})
+			continue
+		}
+		pool.backends = append(pool.backends, b)
+	}
+
+	return pool
+}
+
+// newBackend создает бэкенд из конфигурации; вызывается под p.mux.
+// Бэкенды из стартового конфига проверяются до запуска сервера, поэтому создаются живыми (alive)
+func (p *Pool) newBackend(e config.Backend, alive bool) (*Backend, error) {
+	parsedUrl, err := parseBackendURL(e.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	weight := e.Weight
+	if weight <= 0 {
+		weight = 1
 	}
-	pool.current = 0
-	pool.logger = l
 
-	return &pool
+	return &Backend{
+		URL:               parsedUrl,
+		Proxy:             newReverseProxy(parsedUrl, p.timeouts.Merge(e.Timeouts)),
+		alive:             alive,
+		ActiveConnections: 0,
+		Weight:            weight,
+		healthCheck:       e.HealthCheck,
+		timeouts:          e.Timeouts,
+		Breaker:           NewCircuitBreaker(parsedUrl.String(), p.breakerCfg, p.logger),
+	}, nil
 }
 
-// RR methods
+func parseBackendURL(rawURL string) (*url.URL, error) {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	return parsedUrl, nil
+}
 
 func (p *Pool) GetBackendsLength() int {
-	return len(p.Backends)
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	return len(p.backends)
+}
+
+// List возвращает копию списка бэкендов, которую можно читать без блокировки пула
+func (p *Pool) List() []*Backend {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	backends := make([]*Backend, len(p.backends))
+	copy(backends, p.backends)
+
+	return backends
+}
+
+// Get ищет бэкенд по URL
+func (p *Pool) Get(rawURL string) (*Backend, error) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	i := p.indexOf(rawURL)
+	if i < 0 {
+		return nil, ErrBackendNotFound
+	}
+
+	return p.backends[i], nil
+}
+
+// Add добавляет новый бэкенд в пул. Трафик на него пойдет после healthy_threshold успешных проверок
+func (p *Pool) Add(e config.Backend) (*Backend, error) {
+	return p.add(e, false)
 }
 
-func (p *Pool) Next() *Backend {
+func (p *Pool) add(e config.Backend, alive bool) (*Backend, error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 
-	var nextIndex uint
+	b, err := p.newBackend(e, alive)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.indexOf(b.URL.String()) >= 0 {
+		return nil, ErrBackendExists
+	}
+	p.backends = append(p.backends, b)
+
+	p.logger.Info("Backend added to pool", map[string]interface{}{
+		"url":    b.URL.String(),
+		"weight": b.Weight,
+		"alive":  alive,
+	})
+
+	return b, nil
+}
+
+// Remove убирает бэкенд из пула: новые запросы на него больше не попадают,
+// а текущие дорабатываются. Возвращаемый канал закрывается, когда бэкенд полностью освобожден;
+// после этого удаляются его серии метрик, если бэкенд с тем же URL не вернулся в пул.
+func (p *Pool) Remove(rawURL string) (<-chan struct{}, error) {
+	return p.remove(rawURL, false)
+}
+
+// remove убирает бэкенд из пула; для recreated (бэкенд сразу добавляется заново с новыми настройками) серии метрик сохраняются
+func (p *Pool) remove(rawURL string, recreated bool) (<-chan struct{}, error) {
+	p.mux.Lock()
+	i := p.indexOf(rawURL)
+	if i < 0 {
+		p.mux.Unlock()
+		return nil, ErrBackendNotFound
+	}
+
+	b := p.backends[i]
+	backends := make([]*Backend, 0, len(p.backends)-1)
+	backends = append(backends, p.backends[:i]...)
+	p.backends = append(backends, p.backends[i+1:]...)
+	p.mux.Unlock()
+
+	p.logger.Info("Backend removed from pool, draining", map[string]interface{}{
+		"url":                b.URL.String(),
+		"active_connections": atomic.LoadInt32(&b.ActiveConnections),
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+
+		ticker := time.NewTicker(drainCheckInterval)
+		defer ticker.Stop()
+
+		for atomic.LoadInt32(&b.ActiveConnections) > 0 {
+			<-ticker.C
+		}
 
-	if int(p.current+1) == len(p.Backends) {
-		nextIndex = 0
-	} else {
+		p.mux.RLock()
+		readded := p.indexOf(b.URL.String()) >= 0
+		p.mux.RUnlock()
+		if !recreated && !readded {
+			metrics.DeleteBackend(p.name, b.URL.String())
+		}
+
+		p.logger.Info("Backend drained", map[string]interface{}{
+			"url": b.URL.String(),
+		})
+	}()
+
+	return drained, nil
+}
 
-		nextIndex = p.current + 1
+// SetEnabled включает или отключает бэкенд, не удаляя его из пула
+func (p *Pool) SetEnabled(rawURL string, isEnabled bool) (*Backend, error) {
+	b, err := p.Get(rawURL)
+	if err != nil {
+		return nil, err
 	}
-	p.current = nextIndex
-	return p.Backends[nextIndex]
+
+	b.SetEnabled(isEnabled)
+
+	p.logger.Info("Backend state changed", map[string]interface{}{
+		"url":     b.URL.String(),
+		"enabled": isEnabled,
+	})
+
+	return b, nil
 }
 
-// LC methods
+func (p *Pool) indexOf(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return -1
+	}
+
+	for i, b := range p.backends {
+		if b.URL.String() == u.String() {
+			return i
+		}
+	}
 
-func (p *Pool) GetLessLoadedBackend() *Backend {
-	var lessLoadedBackend *Backend
+	return -1
+}
 
-	p.mux.RLock()
-	defer p.mux.RUnlock()
+// Sync приводит пул к списку из конфигурации: новые бэкенды добавляются, отсутствующие
+// удаляются с drain, а бэкенды с измененным весом или таймаутами пересоздаются. Переопределение healthcheck
+// обновляется на месте. Новые бэкенды получают трафик после проверок, пересозданные сохраняют состояние alive.
+// Состояние (alive, соединения, латентность) неизмененных бэкендов сохраняется.
+func (p *Pool) Sync(entries []config.Backend) error {
+	desired := make(map[string]config.Backend, len(entries))
+	order := make([]string, 0, len(entries))
+	for _, e := range entries {
+		u, err := parseBackendURL(e.URL)
+		if err != nil {
+			return err
+		}
+		key := u.String()
+		if _, exists := desired[key]; !exists {
+			order = append(order, key)
+		}
+		if e.Weight <= 0 {
+			e.Weight = 1
+		}
+		e.URL = key
+		desired[key] = e
+	}
 
-	for _, b := range p.Backends {
-		if !b.IsAlive() {
+	recreated := make(map[string]bool)
+	for _, b := range p.List() {
+		e, exists := desired[b.URL.String()]
+		if exists && e.Weight == b.Weight && reflect.DeepEqual(e.Timeouts, b.timeouts) {
+			b.SetHealthCheck(e.HealthCheck)
+			delete(desired, b.URL.String())
 			continue
 		}
+		if exists {
+			recreated[b.URL.String()] = b.IsAlive()
+		}
+		if _, err := p.remove(b.URL.String(), exists); err != nil && !errors.Is(err, ErrBackendNotFound) {
+			return err
+		}
+	}
 
-		if lessLoadedBackend == nil || b.ActiveConnections < lessLoadedBackend.ActiveConnections {
-			lessLoadedBackend = b
+	for _, key := range order {
+		e, exists := desired[key]
+		if !exists {
+			continue
+		}
+		if _, err := p.add(e, recreated[key]); err != nil && !errors.Is(err, ErrBackendExists) {
+			return err
 		}
 	}
 
-	return lessLoadedBackend
+	return nil
+}
+
+// SetCircuitBreaker применяет настройки circuit breaker ко всем текущим и будущим бэкендам
+func (p *Pool) SetCircuitBreaker(cfg config.CircuitBreaker) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.breakerCfg = cfg
+	for _, b := range p.backends {
+		b.Breaker.Update(cfg)
+	}
 }
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"io"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+func TestRuntimeAddedBackendsWaitForHealthCheck(t *testing.T) {
+	pool := NewPool(config.DefaultPool, config.BackendPool{URLs: []string{"http://127.0.0.1:8081", "http://127.0.0.1:8082"}},
+		logging.NewZeroLoggerWithWriter(4, io.Discard))
+	for _, b := range pool.List() {
+		if !b.IsAvailable() {
+			t.Fatalf("backend %s from startup config is not available", b.URL)
+		}
+	}
+
+	// Добавленный через admin API бэкенд не получает трафик до первых успешных проверок
+	added, err := pool.Add(config.Backend{URL: "http://127.0.0.1:8083"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.IsAvailable() {
+		t.Fatal("backend added via Add is available before its first health check")
+	}
+
+	// При reload: новый бэкенд ждет проверок, а пересозданный из-за веса сохраняет состояние
+	if err := pool.Sync([]config.Backend{
+		{URL: "http://127.0.0.1:8081", Weight: 5},
+		{URL: "http://127.0.0.1:8082"},
+		{URL: "http://127.0.0.1:8084"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url       string
+		available bool
+	}{
+		{url: "http://127.0.0.1:8081", available: true},
+		{url: "http://127.0.0.1:8082", available: true},
+		{url: "http://127.0.0.1:8084", available: false},
+	}
+	for _, tt := range tests {
+		b, err := pool.Get(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.IsAvailable() != tt.available {
+			t.Fatalf("after Sync %s available = %v, want %v", tt.url, b.IsAvailable(), tt.available)
+		}
+	}
+	if b, _ := pool.Get("http://127.0.0.1:8081"); b.Weight != 5 {
+		t.Fatalf("weight = %d, want 5", b.Weight)
+	}
+}
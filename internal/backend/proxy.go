@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
+)
+
+type attemptKey struct{}
+
+// ProxyAttempt описывает одну попытку проксирования. Если она есть в контексте запроса,
+// ReverseProxy бэкенда не пишет ответ 502 сам, а сохраняет ошибку в Err,
+// чтобы вызывающий код мог повторить запрос на другом бэкенде.
+type ProxyAttempt struct {
+	// RetryStatus решает, нужно ли отбросить ответ бэкенда с этим кодом и повторить запрос
+	RetryStatus func(code int) bool
+	// Err - ошибка соединения или RetryableStatusError
+	Err error
+}
+
+// RetryableStatusError возвращается, когда ответ бэкенда отброшен для повтора
+type RetryableStatusError struct {
+	Code int
+}
+
+func (e *RetryableStatusError) Error() string {
+	return fmt.Sprintf("backend responded with retryable status %d", e.Code)
+}
+
+func WithProxyAttempt(ctx context.Context, a *ProxyAttempt) context.Context {
+	return context.WithValue(ctx, attemptKey{}, a)
+}
+
+func proxyAttemptFrom(ctx context.Context) *ProxyAttempt {
+	a, _ := ctx.Value(attemptKey{}).(*ProxyAttempt)
+	return a
+}
+
+func newReverseProxy(target *url.URL, timeouts config.UpstreamTimeouts) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newTransport(timeouts)
+
+	// Контекст трейса (span попытки) передается бэкенду в заголовке traceparent
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		tracing.Inject(req.Context(), req.Header)
+	}
+
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		a := proxyAttemptFrom(resp.Request.Context())
+		if a != nil && a.RetryStatus != nil && a.RetryStatus(resp.StatusCode) {
+			return &RetryableStatusError{Code: resp.StatusCode}
+		}
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if a := proxyAttemptFrom(r.Context()); a != nil {
+			a.Err = err
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	return proxy
+}
+
+// newTransport создает транспорт бэкенда на основе http.DefaultTransport с настроенными таймаутами
+func newTransport(timeouts config.UpstreamTimeouts) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{
+		Timeout:   timeouts.Dial,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	transport.ResponseHeaderTimeout = timeouts.ResponseHeader
+
+	return transport
+}
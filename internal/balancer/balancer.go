@@ -0,0 +1,73 @@
+package balancer
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+// Balancer выбирает бэкенд для запроса. Мертвые бэкенды и бэкенды из exclude должны пропускаться;
+// если подходящих нет, Pick возвращает nil.
+// backends - всегда полный список пула, поэтому состояние, которое алгоритм хранит по списку
+// бэкендов, не сбрасывается при повторах запроса
+type Balancer interface {
+	Pick(r *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend
+}
+
+// Exclude - бэкенды, которые нельзя выбирать для запроса (например, уже опробованные при повторе)
+type Exclude map[*backend.Backend]bool
+
+// Allows сообщает, можно ли выбрать бэкенд: он доступен и не исключен
+func (e Exclude) Allows(b *backend.Backend) bool {
+	return !e[b] && b.IsAvailable()
+}
+
+// Factory создает балансировщик на основе конфигурации сервера
+type Factory func(cfg *config.Server) Balancer
+
+var (
+	registryMux sync.RWMutex
+	registry    = make(map[string]Factory)
+)
+
+// Register регистрирует алгоритм балансировки под именем, которое указывается в lb_method
+func Register(name string, f Factory) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic("balancer: Register called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// New создает балансировщик по имени; для незарегистрированного имени возвращает ошибку
+func New(name string, cfg *config.Server) (Balancer, error) {
+	registryMux.RLock()
+	f, exists := registry[name]
+	registryMux.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown lb_method %q, available: %v", name, Names())
+	}
+
+	return f(cfg), nil
+}
+
+// Names возвращает отсортированный список зарегистрированных алгоритмов
+func Names() []string {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,170 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func newTestBackends(t *testing.T, weights ...int) []*backend.Backend {
+	t.Helper()
+
+	backends := make([]*backend.Backend, 0, len(weights))
+	for i, w := range weights {
+		u, err := url.Parse("http://127.0.0.1:" + strconv.Itoa(9000+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &backend.Backend{URL: u, Weight: w}
+		b.SetAlive(true)
+		backends = append(backends, b)
+	}
+	return backends
+}
+
+func TestPickSkipsExcluded(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			lb, err := New(name, &config.Server{Hash: config.Hash{Key: "ip", VirtualNodes: 10}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			backends := newTestBackends(t, 1, 2, 3)
+			r := httptest.NewRequest("GET", "/", nil)
+
+			exclude := make(Exclude)
+			for range backends {
+				b := lb.Pick(r, backends, exclude)
+				if b == nil {
+					t.Fatal("Pick() = nil with available backends left")
+				}
+				if exclude[b] {
+					t.Fatalf("Pick() returned excluded backend %s", b.URL)
+				}
+				exclude[b] = true
+			}
+			if b := lb.Pick(r, backends, exclude); b != nil {
+				t.Fatalf("Pick() = %s with all backends excluded", b.URL)
+			}
+		})
+	}
+}
+
+func TestConsistentHashRetryKeepsRing(t *testing.T) {
+	ch := &ConsistentHash{cfg: config.Hash{Key: "ip", VirtualNodes: 10}}
+	backends := newTestBackends(t, 1, 1, 1)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	first := ch.Pick(r, backends, nil)
+	ring := ch.ring
+
+	// Повтор на другом бэкенде не перестраивает кольцо
+	retry := ch.Pick(r, backends, Exclude{first: true})
+	if retry == nil || retry == first {
+		t.Fatalf("retry picked %v, want another backend than %s", retry, first.URL)
+	}
+	if ch.ring != ring {
+		t.Fatal("ring was rebuilt on retry")
+	}
+
+	// Следующий обычный запрос с тем же ключом снова попадает на первый бэкенд
+	if b := ch.Pick(r, backends, nil); b != first || ch.ring != ring {
+		t.Fatal("ring changed after retry")
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	wrr := &WeightedRoundRobin{current: make(map[*backend.Backend]int)}
+	backends := newTestBackends(t, 5, 1, 1)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	// Порядок smooth WRR для весов 5, 1, 1 (как в nginx): a a b a c a a
+	want := []int{0, 0, 1, 0, 2, 0, 0}
+	for i, idx := range want {
+		if b := wrr.Pick(r, backends, nil); b != backends[idx] {
+			t.Fatalf("pick %d = %s, want %s", i, b.URL, backends[idx].URL)
+		}
+	}
+
+	// Повтор с исключенным бэкендом не сбрасывает накопленные веса
+	wrr.Pick(r, backends, Exclude{backends[0]: true})
+	if len(wrr.current) != len(backends) {
+		t.Fatalf("current has %d entries after retry, want %d", len(wrr.current), len(backends))
+	}
+
+	// Удаленный (или пересозданный Pool.Sync) бэкенд забывается
+	wrr.Pick(r, backends[1:], nil)
+	if _, exists := wrr.current[backends[0]]; exists || len(wrr.current) != 2 {
+		t.Fatalf("current was not pruned: %d entries", len(wrr.current))
+	}
+}
+
+func TestConsistentHashRemapsOnlyLostBackendKeys(t *testing.T) {
+	cfg := config.Hash{Key: "header", Name: "X-User", VirtualNodes: 100}
+	backends := newTestBackends(t, 1, 1, 1, 1, 1)
+	lost := backends[2]
+
+	requests := make([]*http.Request, 1000)
+	for i := range requests {
+		requests[i] = httptest.NewRequest("GET", "/", nil)
+		requests[i].Header.Set("X-User", "user-"+strconv.Itoa(i))
+	}
+	assign := func(ch *ConsistentHash, pool []*backend.Backend) []*backend.Backend {
+		got := make([]*backend.Backend, len(requests))
+		for i, r := range requests {
+			got[i] = ch.Pick(r, pool, nil)
+		}
+		return got
+	}
+
+	before := assign(&ConsistentHash{cfg: cfg}, backends)
+
+	tests := []struct {
+		name string
+		pick func() []*backend.Backend
+	}{
+		{
+			// Бэкенд исключен outlier detection или health check: кольцо не меняется
+			name: "ejected",
+			pick: func() []*backend.Backend {
+				lost.SetAlive(false)
+				defer lost.SetAlive(true)
+				return assign(&ConsistentHash{cfg: cfg}, backends)
+			},
+		},
+		{
+			// Бэкенд удален из пула: кольцо перестраивается без его виртуальных узлов
+			name: "removed",
+			pick: func() []*backend.Backend {
+				pool := append(append([]*backend.Backend{}, backends[:2]...), backends[3:]...)
+				return assign(&ConsistentHash{cfg: cfg}, pool)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.pick()
+			moved := 0
+			for i := range requests {
+				switch {
+				case before[i] == lost:
+					if after[i] == lost || after[i] == nil {
+						t.Fatalf("key %d stayed on the lost backend", i)
+					}
+					moved++
+				case after[i] != before[i]:
+					t.Fatalf("key %d moved from %s to %s, but its backend is still in the pool", i, before[i].URL, after[i].URL)
+				}
+			}
+			if moved == 0 {
+				t.Fatal("no keys were assigned to the lost backend")
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/netutil"
+)
+
+func init() {
+	Register("CH", func(cfg *config.Server) Balancer {
+		return &ConsistentHash{cfg: cfg.Hash}
+	})
+}
+
+// ConsistentHash направляет запросы с одинаковым ключом на один и тот же бэкенд.
+// Кольцо перестраивается только при изменении состава пула; при повторе запроса
+// опробованные бэкенды пропускаются при обходе кольца.
+type ConsistentHash struct {
+	cfg config.Hash
+
+	mux      sync.Mutex
+	ring     *HashRing
+	backends []*backend.Backend
+}
+
+func (ch *ConsistentHash) Pick(r *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend {
+	return ch.getRing(backends).Get(requestHashKey(r, ch.cfg), exclude)
+}
+
+func (ch *ConsistentHash) getRing(backends []*backend.Backend) *HashRing {
+	ch.mux.Lock()
+	defer ch.mux.Unlock()
+
+	if ch.ring == nil || !sameBackends(ch.backends, backends) {
+		ch.ring = NewHashRing(backends, ch.cfg.VirtualNodes)
+		ch.backends = append(ch.backends[:0], backends...)
+	}
+
+	return ch.ring
+}
+
+func sameBackends(a, b []*backend.Backend) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHashKey извлекает ключ для consistent hash; если заголовка или cookie нет, используется IP клиента
+func requestHashKey(r *http.Request, cfg config.Hash) string {
+	switch cfg.Key {
+	case "header":
+		if v := r.Header.Get(cfg.Name); v != "" {
+			return v
+		}
+	case "cookie":
+		if c, err := r.Cookie(cfg.Name); err == nil && c.Value != "" {
+			return c.Value
+		}
+	case "path":
+		return r.URL.Path
+	}
+
+	return netutil.ClientIP(r)
+}
@@ -0,0 +1,73 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+)
+
+// HashRing - кольцо consistent hash с виртуальными узлами.
+// Кольцо неизменяемо после создания, поэтому читается без блокировок.
+type HashRing struct {
+	hashes []uint64
+	nodes  map[uint64]*backend.Backend
+}
+
+func NewHashRing(backends []*backend.Backend, virtualNodes int) *HashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+
+	ring := &HashRing{
+		nodes: make(map[uint64]*backend.Backend, len(backends)*virtualNodes),
+	}
+
+	for _, b := range backends {
+		// Бэкенды с большим весом получают пропорционально больше виртуальных узлов
+		replicas := virtualNodes * b.Weight
+		if replicas <= 0 {
+			replicas = virtualNodes
+		}
+
+		for i := 0; i < replicas; i++ {
+			h := hashKey(b.URL.String() + "#" + strconv.Itoa(i))
+			if _, exists := ring.nodes[h]; exists {
+				continue
+			}
+			ring.nodes[h] = b
+			ring.hashes = append(ring.hashes, h)
+		}
+	}
+
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+// Get возвращает бэкенд для ключа: первый живой и не исключенный узел по часовой стрелке от хэша ключа.
+// Если бэкенд упал, на соседей переезжают только его ключи.
+func (hr *HashRing) Get(key string, exclude Exclude) *backend.Backend {
+	if len(hr.hashes) == 0 {
+		return nil
+	}
+
+	h := hashKey(key)
+	start := sort.Search(len(hr.hashes), func(i int) bool { return hr.hashes[i] >= h })
+
+	for i := 0; i < len(hr.hashes); i++ {
+		b := hr.nodes[hr.hashes[(start+i)%len(hr.hashes)]]
+		if exclude.Allows(b) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,32 @@
+package balancer
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func init() {
+	Register("LC", func(*config.Server) Balancer { return &LeastConnections{} })
+}
+
+// LeastConnections выбирает живой бэкенд с наименьшим количеством активных соединений
+type LeastConnections struct{}
+
+func (LeastConnections) Pick(_ *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend {
+	var lessLoadedBackend *backend.Backend
+
+	for _, b := range backends {
+		if !exclude.Allows(b) {
+			continue
+		}
+
+		if lessLoadedBackend == nil || atomic.LoadInt32(&b.ActiveConnections) < atomic.LoadInt32(&lessLoadedBackend.ActiveConnections) {
+			lessLoadedBackend = b
+		}
+	}
+
+	return lessLoadedBackend
+}
@@ -0,0 +1,56 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func init() {
+	Register("P2C", func(*config.Server) Balancer {
+		return &TwoChoices{score: func(b *backend.Backend) float64 {
+			return float64(atomic.LoadInt32(&b.ActiveConnections))
+		}}
+	})
+	Register("EWMA", func(*config.Server) Balancer {
+		return &TwoChoices{score: func(b *backend.Backend) float64 {
+			return b.Load()
+		}}
+	})
+}
+
+// TwoChoices выбирает два случайных живых бэкенда и возвращает тот, у которого меньше score:
+// для P2C это ActiveConnections, для EWMA - латентность * активные запросы
+type TwoChoices struct {
+	score func(b *backend.Backend) float64
+}
+
+func (tc *TwoChoices) Pick(_ *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend {
+	alive := make([]*backend.Backend, 0, len(backends))
+	for _, b := range backends {
+		if exclude.Allows(b) {
+			alive = append(alive, b)
+		}
+	}
+
+	switch len(alive) {
+	case 0:
+		return nil
+	case 1:
+		return alive[0]
+	}
+
+	i := rand.IntN(len(alive))
+	j := rand.IntN(len(alive) - 1)
+	if j >= i {
+		j++
+	}
+
+	if tc.score(alive[j]) < tc.score(alive[i]) {
+		return alive[j]
+	}
+	return alive[i]
+}
@@ -0,0 +1,31 @@
+package balancer
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func init() {
+	Register("RR", func(*config.Server) Balancer { return &RoundRobin{} })
+}
+
+// RoundRobin распределяет запросы по очереди, пропуская мертвые бэкенды
+type RoundRobin struct {
+	current uint64
+}
+
+func (rr *RoundRobin) Pick(_ *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend {
+	n := uint64(len(backends))
+
+	for i := uint64(0); i < n; i++ {
+		b := backends[atomic.AddUint64(&rr.current, 1)%n]
+		if exclude.Allows(b) {
+			return b
+		}
+	}
+
+	return nil
+}
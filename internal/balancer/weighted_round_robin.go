@@ -0,0 +1,74 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func init() {
+	Register("WRR", func(*config.Server) Balancer {
+		return &WeightedRoundRobin{current: make(map[*backend.Backend]int)}
+	})
+}
+
+// WeightedRoundRobin - smooth weighted round robin (как в nginx).
+// Мертвые бэкенды пропускаются и не участвуют в расчете суммарного веса.
+type WeightedRoundRobin struct {
+	mux     sync.Mutex
+	current map[*backend.Backend]int
+}
+
+func (wrr *WeightedRoundRobin) Pick(_ *http.Request, backends []*backend.Backend, exclude Exclude) *backend.Backend {
+	wrr.mux.Lock()
+	defer wrr.mux.Unlock()
+
+	var best *backend.Backend
+	total := 0
+
+	for _, b := range backends {
+		if !exclude.Allows(b) {
+			// Запись заводится и для пропущенных бэкендов: тогда записей больше, чем бэкендов,
+			// только если в current остались бэкенды, которых уже нет в списке
+			if _, exists := wrr.current[b]; !exists {
+				wrr.current[b] = 0
+			}
+			continue
+		}
+
+		wrr.current[b] += b.Weight
+		total += b.Weight
+
+		if best == nil || wrr.current[b] > wrr.current[best] {
+			best = b
+		}
+	}
+
+	// Бэкенды, пересозданные Pool.Sync или удаленные через admin API, больше не приходят в backends
+	if len(wrr.current) > len(backends) {
+		wrr.prune(backends)
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	wrr.current[best] -= total
+
+	return best
+}
+
+// prune удаляет текущие веса бэкендов, которых нет в backends; вызывается под wrr.mux
+func (wrr *WeightedRoundRobin) prune(backends []*backend.Backend) {
+	keep := make(map[*backend.Backend]struct{}, len(backends))
+	for _, b := range backends {
+		keep[b] = struct{}{}
+	}
+	for b := range wrr.current {
+		if _, exists := keep[b]; !exists {
+			delete(wrr.current, b)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package clock
+
+import "time"
+
+// Clock - источник времени. Компоненты, зависящие от времени (rate limiter, circuit breaker),
+// получают его только через Clock, поэтому в тестах их можно проверять детерминированно
+type Clock interface {
+	Now() time.Time
+}
+
+// System - системные часы
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
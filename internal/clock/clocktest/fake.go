@@ -0,0 +1,40 @@
+package clocktest
+
+import (
+	"sync"
+	"time"
+)
+
+// Start - момент, с которого начинают тестовые часы
+var Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Fake - часы, которые двигаются только вручную
+type Fake struct {
+	mux sync.Mutex
+	t   time.Time
+}
+
+// NewFake создает часы, остановленные на Start
+func NewFake() *Fake {
+	return &Fake{t: Start}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.t
+}
+
+// Set переводит часы на t
+func (c *Fake) Set(t time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.t = t
+}
+
+// Advance сдвигает часы на d
+func (c *Fake) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.t = c.t.Add(d)
+}
@@ -1,43 +1,644 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	Server      Server      `yaml:"server"`
 	LoggerLevel int8        `yaml:"logger_level"`
 	BackendPool BackendPool `yaml:"pool"`
-	RateLimiter RateLimiter `yaml:"rate_limiter"`
+	// Pools - дополнительные именованные пулы, на которые ссылаются маршруты
+	Pools       map[string]BackendPool `yaml:"pools"`
+	Routes      []Route                `yaml:"routes"`
+	RateLimiter RateLimiter            `yaml:"rate_limiter"`
+	Reload      Reload                 `yaml:"reload"`
+	Tracing     Tracing                `yaml:"tracing"`
+	AccessLog   AccessLog              `yaml:"access_log"`
+	Admin       Admin                  `yaml:"admin"`
 }
 
-type Server struct {
-	Port     string `env:"PORT" env-default:"8080" yaml:"port"`
+// Роли admin API: read - только GET-запросы, write - любые
+const (
+	AdminRoleRead  = "read"
+	AdminRoleWrite = "write"
+)
+
+// Admin настройки admin API (/api/...). API обслуживается только на отдельном listener'е Listen
+// и запускается, только если задан способ аутентификации
+type Admin struct {
+	// Listen - адрес отдельного listener'а (host:port) или unix-сокет вида unix:/run/lb-admin.sock.
+	// На основном порту admin API не обслуживается
+	Listen string `yaml:"listen" env:"ADMIN_LISTEN" env-default:"127.0.0.1:9090"`
+	// Tokens - bearer-токены клиентов admin API
+	Tokens []AdminToken `yaml:"tokens"`
+	TLS    AdminTLS     `yaml:"tls"`
+	// AuditLog - файл журнала изменений (пустой - основной логгер)
+	AuditLog string `yaml:"audit_log"`
+}
+
+// AuthEnabled сообщает, задан ли хотя бы один способ аутентификации; без него admin API не запускается
+func (a *Admin) AuthEnabled() bool {
+	return len(a.Tokens) > 0 || a.TLS.ClientCAFile != ""
+}
+
+// AdminToken bearer-токен и роль его владельца; name пишется в audit log
+type AdminToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"`
+}
+
+// AdminTLS сертификат admin listener'а. С client_ca_file клиенты аутентифицируются
+// сертификатом (mTLS), роль определяется по Common Name
+type AdminTLS struct {
+	CertFile     string        `yaml:"cert_file"`
+	KeyFile      string        `yaml:"key_file"`
+	ClientCAFile string        `yaml:"client_ca_file"`
+	Clients      []AdminClient `yaml:"clients"`
+}
+
+type AdminClient struct {
+	CommonName string `yaml:"common_name"`
+	Role       string `yaml:"role"`
+}
+
+// Форматы access log
+const (
+	AccessLogJSON     = "json"
+	AccessLogCombined = "combined"
+	AccessLogTemplate = "template"
+)
+
+// AccessLog настройки журнала запросов: одна строка на запрос
+type AccessLog struct {
+	Enabled bool `yaml:"enabled"`
+	// Format - json, combined (Apache combined) или template
+	Format string `yaml:"format" env-default:"json"`
+	// Template - шаблон строки для format: template, переменные вида $status или ${status}
+	Template string `yaml:"template"`
+	// File - файл журнала с ротацией по размеру (пустой - stdout)
+	File       string `yaml:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb" env-default:"100"`
+	MaxBackups int    `yaml:"max_backups" env-default:"5"`
+	// SuccessSampleRate - доля записываемых успешных (код ответа < 400) запросов; ошибки пишутся всегда
+	SuccessSampleRate float64 `yaml:"success_sample_rate"`
+}
+
+// UnmarshalYAML подставляет success_sample_rate по умолчанию только для отсутствующего ключа:
+// с env-default явный 0 (писать только ошибки) превращался бы в 1
+func (al *AccessLog) UnmarshalYAML(value *yaml.Node) error {
+	type plain AccessLog
+	p := plain{SuccessSampleRate: 1}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*al = AccessLog(p)
+	return nil
+}
+
+// Протоколы экспорта OTLP
+const (
+	OTLPGRPC = "grpc"
+	OTLPHTTP = "http"
+)
+
+// Tracing настройки OpenTelemetry трейсинга. Заголовок traceparent передается бэкендам
+// и при выключенном экспорте, чтобы не разрывать трейсы
+type Tracing struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint - адрес OTLP коллектора (host:port)
+	Endpoint string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" env-default:"localhost:4317"`
+	// Protocol - grpc или http
+	Protocol string `yaml:"protocol" env-default:"grpc"`
+	// Insecure - соединение с коллектором без TLS
+	Insecure    bool   `yaml:"insecure"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME" env-default:"cloud-camp-lb"`
+	// SampleRatio - доля новых трейсов, которые записываются; для входящих трейсов учитывается решение родителя
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// UnmarshalYAML подставляет sample_ratio по умолчанию только для отсутствующего ключа:
+// с env-default явный 0 (не записывать новые трейсы) превращался бы в 1
+func (t *Tracing) UnmarshalYAML(value *yaml.Node) error {
+	type plain Tracing
+	p := plain{SampleRatio: 1}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*t = Tracing(p)
+	return nil
+}
+
+// DefaultPool - имя пула из блока pool; в него уходят запросы, не подошедшие ни под один маршрут
+const DefaultPool = "default"
+
+// AllPools возвращает все пулы конфигурации по именам, включая пул по умолчанию
+func (c *Config) AllPools() map[string]BackendPool {
+	pools := make(map[string]BackendPool, len(c.Pools)+1)
+	for name, bp := range c.Pools {
+		pools[name] = bp
+	}
+	pools[DefaultPool] = c.BackendPool
+	return pools
+}
+
+// Route правило маршрутизации. Запрос, подходящий под все заданные условия, уходит в пул Pool;
+// маршруты проверяются по порядку, выигрывает первый подошедший
+type Route struct {
+	// Name - имя маршрута для admin API (обязательно для маршрутов с canary)
+	Name string `yaml:"name"`
+	// Host - точное имя хоста или шаблон вида *.example.com
+	Host       string            `yaml:"host"`
+	PathPrefix string            `yaml:"path_prefix"`
+	PathRegex  string            `yaml:"path_regex"`
+	Methods    []string          `yaml:"methods"`
+	Headers    map[string]string `yaml:"headers"`
+
+	// Pool - имя пула из pools (пустое - пул по умолчанию)
+	Pool string `yaml:"pool"`
+	// LBMethod и Hash переопределяют server.lb_method и server.hash для маршрута
 	LBMethod string `yaml:"lb_method"`
+	Hash     *Hash  `yaml:"hash"`
+
+	// StripPrefix убирает path_prefix из пути перед проксированием
+	StripPrefix bool `yaml:"strip_prefix"`
+	// Rewrite заменяет path_prefix (или совпадение path_regex, с поддержкой $1) в пути
+	Rewrite string `yaml:"rewrite"`
+
+	// Canary отправляет часть трафика маршрута в другой пул
+	Canary *Canary `yaml:"canary"`
+	// Mirror копирует часть запросов маршрута в теневой пул, ответы теневого пула отбрасываются
+	Mirror *Mirror `yaml:"mirror"`
+}
+
+// Mirror настройки зеркалирования трафика. Незаданные значения заменяются значениями по умолчанию
+type Mirror struct {
+	Pool string `yaml:"pool"`
+	// Percent - доля зеркалируемых запросов, в процентах
+	Percent float64 `yaml:"percent"`
+	// MaxBodySize - запросы с телом больше этого размера (по умолчанию 65536) не зеркалируются; 0 - только запросы без тела
+	MaxBodySize int64 `yaml:"max_body_size"`
+	// Header - заголовок со значением true, которым помечаются теневые запросы (по умолчанию X-Mirrored-Request)
+	Header string `yaml:"header"`
+	// Timeout - дедлайн теневого запроса (по умолчанию 10s, 0 - без дедлайна)
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxConcurrent - лимит одновременных теневых запросов, сверх него запросы не зеркалируются (по умолчанию 100)
+	MaxConcurrent int `yaml:"max_concurrent"`
+}
+
+// UnmarshalYAML подставляет значения по умолчанию только для отсутствующих ключей, чтобы явный 0 сохранялся
+func (m *Mirror) UnmarshalYAML(value *yaml.Node) error {
+	type plain Mirror
+	p := plain{
+		MaxBodySize:   65536,
+		Header:        "X-Mirrored-Request",
+		Timeout:       10 * time.Second,
+		MaxConcurrent: 100,
+	}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*m = Mirror(p)
+	return nil
+}
+
+// Способы закрепления клиента за вариантом canary-сплита
+const (
+	CanaryStickyIP     = "ip"
+	CanaryStickyCookie = "cookie"
+)
+
+// Canary настройки разделения трафика маршрута между основным пулом и canary пулом
+type Canary struct {
+	Pool string `yaml:"pool"`
+	// Weight - доля трафика в canary пул, в процентах
+	Weight int `yaml:"weight"`
+	// Sticky - как закреплять клиента за вариантом: ip (хеш IP клиента, по умолчанию) или cookie
+	Sticky string `yaml:"sticky"`
+	// Cookie - имя cookie для sticky: cookie (по умолчанию lb_canary)
+	Cookie string `yaml:"cookie"`
+	// Header - заголовок принудительного выбора: always - canary пул, never - основной (по умолчанию X-Canary)
+	Header string `yaml:"header"`
+}
+
+// PoolName возвращает имя пула маршрута с учетом пула по умолчанию
+func (rt *Route) PoolName() string {
+	if rt.Pool == "" {
+		return DefaultPool
+	}
+	return rt.Pool
+}
+
+// Balancing возвращает настройки сервера с алгоритмом балансировки маршрута
+func (rt *Route) Balancing(srv *Server) *Server {
+	cfg := *srv
+	if rt.LBMethod != "" {
+		cfg.LBMethod = rt.LBMethod
+	}
+	if rt.Hash != nil {
+		cfg.Hash = *rt.Hash
+		if cfg.Hash.Key == "" {
+			cfg.Hash.Key = srv.Hash.Key
+		}
+		if cfg.Hash.VirtualNodes <= 0 {
+			cfg.Hash.VirtualNodes = srv.Hash.VirtualNodes
+		}
+	}
+	return &cfg
+}
+
+// Reload настройки hot reload конфигурации. SIGHUP работает всегда,
+// отслеживание изменений файла включается через watch
+type Reload struct {
+	Watch         bool          `yaml:"watch"`
+	WatchInterval time.Duration `yaml:"watch_interval" env-default:"5s"`
+}
+
+type Server struct {
+	Port     string         `env:"PORT" env-default:"8080" yaml:"port"`
+	LBMethod string         `yaml:"lb_method" env-default:"RR"`
+	Hash     Hash           `yaml:"hash"`
+	Retry    Retry          `yaml:"retry"`
+	Timeouts ServerTimeouts `yaml:"timeouts"`
+}
+
+// ServerTimeouts таймауты входящих соединений и общий дедлайн запроса
+type ServerTimeouts struct {
+	// ReadHeader - время на чтение заголовков запроса (защита от slowloris)
+	ReadHeader time.Duration `yaml:"read_header" env-default:"10s"`
+	Read       time.Duration `yaml:"read"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle" env-default:"120s"`
+	// Request - общий дедлайн проксируемого запроса, включая повторы (0 - без ограничения)
+	Request time.Duration `yaml:"request"`
+	// Routes переопределяют Request для путей с указанным префиксом (выигрывает самый длинный префикс)
+	Routes []RouteTimeout `yaml:"routes"`
+}
+
+type RouteTimeout struct {
+	PathPrefix string        `yaml:"path_prefix"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// RequestTimeout возвращает дедлайн запроса для пути
+func (t ServerTimeouts) RequestTimeout(path string) time.Duration {
+	timeout, matched := t.Request, ""
+	for _, rt := range t.Routes {
+		if strings.HasPrefix(path, rt.PathPrefix) && len(rt.PathPrefix) > len(matched) {
+			timeout, matched = rt.Timeout, rt.PathPrefix
+		}
+	}
+	return timeout
+}
+
+// UpstreamTimeouts таймауты соединений с бэкендами
+type UpstreamTimeouts struct {
+	Dial           time.Duration `yaml:"dial" env-default:"5s"`
+	TLSHandshake   time.Duration `yaml:"tls_handshake" env-default:"5s"`
+	ResponseHeader time.Duration `yaml:"response_header"`
+}
+
+// Merge возвращает копию таймаутов, в которой заданные в override значения заменяют глобальные
+func (t UpstreamTimeouts) Merge(override *UpstreamTimeouts) UpstreamTimeouts {
+	if override == nil {
+		return t
+	}
+
+	merged := t
+	if override.Dial > 0 {
+		merged.Dial = override.Dial
+	}
+	if override.TLSHandshake > 0 {
+		merged.TLSHandshake = override.TLSHandshake
+	}
+	if override.ResponseHeader > 0 {
+		merged.ResponseHeader = override.ResponseHeader
+	}
+
+	return merged
+}
+
+// Retry настройки повторов запроса на другом живом бэкенде
+type Retry struct {
+	// Attempts - сколько раз можно повторить запрос (0 - повторы выключены)
+	Attempts int `yaml:"attempts"`
+	// RetryOnStatus - коды ответа бэкенда, при которых запрос повторяется (помимо ошибок соединения)
+	RetryOnStatus []int `yaml:"retry_on_status"`
+	// BudgetPercent - максимальная доля повторов от всех запросов, в процентах
+	BudgetPercent float64 `yaml:"budget_percent"`
+	// MinRetriesPerSecond - сколько повторов в секунду разрешено независимо от бюджета (для малого трафика)
+	MinRetriesPerSecond int `yaml:"min_retries_per_second"`
+	// NonIdempotent разрешает повторять POST, PATCH и другие неидемпотентные методы
+	NonIdempotent bool `yaml:"non_idempotent"`
+	// MaxBodySize - тела запросов до этого размера буферизуются для повтора; большие не повторяются
+	MaxBodySize int64 `yaml:"max_body_size"`
+}
+
+// UnmarshalYAML подставляет значения по умолчанию только для отсутствующих ключей:
+// с env-default явный 0 в budget_percent или min_retries_per_second нельзя было бы задать
+func (rt *Retry) UnmarshalYAML(value *yaml.Node) error {
+	type plain Retry
+	p := plain{
+		BudgetPercent:       20,
+		MinRetriesPerSecond: 3,
+		MaxBodySize:         65536,
+	}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*rt = Retry(p)
+	return nil
+}
+
+// Hash настройки consistent hash балансировки (lb_method: CH)
+type Hash struct {
+	// Key источник ключа: ip, header, cookie или path
+	Key string `yaml:"key" env-default:"ip"`
+	// Name имя заголовка или cookie для key: header/cookie
+	Name         string `yaml:"name"`
+	VirtualNodes int    `yaml:"virtual_nodes" env-default:"100"`
 }
 
 type BackendPool struct {
-	URLs        []string    `yaml:"urls"`
-	HealthCheck HealthCheck `yaml:"healthcheck"`
+	URLs             []string         `yaml:"urls"`
+	Backends         []Backend        `yaml:"backends"`
+	HealthCheck      HealthCheck      `yaml:"healthcheck"`
+	OutlierDetection OutlierDetection `yaml:"outlier_detection"`
+	CircuitBreaker   CircuitBreaker   `yaml:"circuit_breaker"`
+	Timeouts         UpstreamTimeouts `yaml:"timeouts"`
+}
+
+// Backend описывает отдельный бэкенд пула с опциональным весом (по умолчанию 1)
+// и опциональным переопределением глобального блока healthcheck
+type Backend struct {
+	URL         string            `yaml:"url"`
+	Weight      int               `yaml:"weight"`
+	HealthCheck *HealthCheck      `yaml:"healthcheck"`
+	Timeouts    *UpstreamTimeouts `yaml:"timeouts"`
+}
+
+// Entries возвращает все бэкенды пула: сначала из urls (с весом 1), затем из backends
+func (bp *BackendPool) Entries() []Backend {
+	entries := make([]Backend, 0, len(bp.URLs)+len(bp.Backends))
+	for _, u := range bp.URLs {
+		entries = append(entries, Backend{URL: u, Weight: 1})
+	}
+	for _, b := range bp.Backends {
+		if b.Weight <= 0 {
+			b.Weight = 1
+		}
+		entries = append(entries, b)
+	}
+	return entries
 }
 
+// Типы активных проверок
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckTCP  = "tcp"
+	HealthCheckGRPC = "grpc"
+)
+
 type HealthCheck struct {
 	Timeout  time.Duration `yaml:"timeout"`
 	Endpoint string        `yaml:"endpoint"`
+	// Interval - период между циклами проверки (только в глобальном блоке)
+	Interval time.Duration `yaml:"interval" env-default:"5s"`
+	// Jitter - случайная добавка к Interval, чтобы несколько балансировщиков не проверяли бэкенды синхронно
+	Jitter time.Duration `yaml:"jitter"`
+	// Concurrency - сколько бэкендов проверяется одновременно
+	Concurrency int `yaml:"concurrency" env-default:"10"`
+	// Type - тип проверки: http (по умолчанию), tcp или grpc
+	Type string `yaml:"type"`
+	// Port - порт для проверки, если он отличается от порта бэкенда
+	Port int `yaml:"port"`
+	// GRPCService - имя сервиса для grpc.health.v1.Health/Check (пустое - весь сервер)
+	GRPCService string `yaml:"grpc_service"`
+	// Method HTTP-метод проверки, по умолчанию GET
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	// Host переопределяет заголовок Host в запросе проверки
+	Host string `yaml:"host"`
+	// ExpectedStatuses - допустимые коды ответа: "200" или диапазоны "200-299", по умолчанию 200
+	ExpectedStatuses []string `yaml:"expected_statuses"`
+	// ExpectedBody - подстрока, которая должна быть в теле ответа
+	ExpectedBody string `yaml:"expected_body"`
+	// ExpectedBodyRegex - регулярное выражение для тела ответа
+	ExpectedBodyRegex string `yaml:"expected_body_regex"`
+	// HealthyThreshold - сколько успешных проверок подряд нужно, чтобы вернуть бэкенд в работу
+	HealthyThreshold int `yaml:"healthy_threshold"`
+	// UnhealthyThreshold - сколько неудачных проверок подряд нужно, чтобы вывести бэкенд из работы
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"`
+}
+
+// Merge возвращает копию настроек, в которой непустые поля override заменяют глобальные
+func (hc HealthCheck) Merge(override *HealthCheck) HealthCheck {
+	if override == nil {
+		return hc
+	}
+
+	merged := hc
+	if override.Timeout > 0 {
+		merged.Timeout = override.Timeout
+	}
+	if override.Endpoint != "" {
+		merged.Endpoint = override.Endpoint
+	}
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Port > 0 {
+		merged.Port = override.Port
+	}
+	if override.GRPCService != "" {
+		merged.GRPCService = override.GRPCService
+	}
+	if override.Method != "" {
+		merged.Method = override.Method
+	}
+	if len(override.Headers) > 0 {
+		merged.Headers = override.Headers
+	}
+	if override.Host != "" {
+		merged.Host = override.Host
+	}
+	if len(override.ExpectedStatuses) > 0 {
+		merged.ExpectedStatuses = override.ExpectedStatuses
+	}
+	if override.ExpectedBody != "" {
+		merged.ExpectedBody = override.ExpectedBody
+	}
+	if override.ExpectedBodyRegex != "" {
+		merged.ExpectedBodyRegex = override.ExpectedBodyRegex
+	}
+	if override.HealthyThreshold > 0 {
+		merged.HealthyThreshold = override.HealthyThreshold
+	}
+	if override.UnhealthyThreshold > 0 {
+		merged.UnhealthyThreshold = override.UnhealthyThreshold
+	}
+
+	return merged
+}
+
+// StatusRanges разбирает ExpectedStatuses в список диапазонов [from, to]
+func (hc HealthCheck) StatusRanges() ([][2]int, error) {
+	if len(hc.ExpectedStatuses) == 0 {
+		return [][2]int{{200, 200}}, nil
+	}
+
+	ranges := make([][2]int, 0, len(hc.ExpectedStatuses))
+	for _, s := range hc.ExpectedStatuses {
+		from, to, isRange := strings.Cut(strings.TrimSpace(s), "-")
+		if !isRange {
+			to = from
+		}
+
+		f, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected status %q", s)
+		}
+		t, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || t < f {
+			return nil, fmt.Errorf("invalid expected status %q", s)
+		}
+
+		ranges = append(ranges, [2]int{f, t})
+	}
+
+	return ranges, nil
+}
+
+// Validate проверяет настройки проверки (в том числе после Merge с переопределением бэкенда)
+func (hc HealthCheck) Validate() error {
+	if hc.Timeout <= 0 {
+		return errors.New("healthcheck.timeout must be positive")
+	}
+	switch hc.Type {
+	case "", HealthCheckHTTP, HealthCheckTCP, HealthCheckGRPC:
+	default:
+		return fmt.Errorf("healthcheck.type: unknown type %q", hc.Type)
+	}
+	if hc.Port < 0 || hc.Port > 65535 {
+		return errors.New("healthcheck.port must be in [0, 65535]")
+	}
+	if _, err := hc.StatusRanges(); err != nil {
+		return fmt.Errorf("healthcheck: %w", err)
+	}
+	if hc.ExpectedBodyRegex != "" {
+		if _, err := regexp.Compile(hc.ExpectedBodyRegex); err != nil {
+			return fmt.Errorf("healthcheck.expected_body_regex: %w", err)
+		}
+	}
+	if hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+		return errors.New("healthcheck thresholds must not be negative")
+	}
+	return nil
+}
+
+// OutlierDetection настройки пассивной проверки бэкендов по проксируемому трафику
+type OutlierDetection struct {
+	Enabled bool `yaml:"enabled"`
+	// ConsecutiveFailures - сколько ошибок подряд (5xx или ошибка соединения) приводят к исключению бэкенда
+	ConsecutiveFailures int `yaml:"consecutive_failures"`
+	// ErrorRate - доля ошибок за Interval (0..1), при превышении которой бэкенд исключается
+	ErrorRate float64 `yaml:"error_rate"`
+	// MinRequests - минимум запросов за Interval, чтобы учитывать ErrorRate
+	MinRequests int           `yaml:"min_requests" env-default:"20"`
+	Interval    time.Duration `yaml:"interval" env-default:"10s"`
+	// BaseEjectionTime удваивается при каждом повторном исключении, но не больше MaxEjectionTime
+	BaseEjectionTime time.Duration `yaml:"base_ejection_time" env-default:"30s"`
+	MaxEjectionTime  time.Duration `yaml:"max_ejection_time" env-default:"5m"`
+	// MaxEjectedPercent - максимальная доля пула (в процентах), которая может быть исключена одновременно
+	MaxEjectedPercent int `yaml:"max_ejected_percent"`
+}
+
+// UnmarshalYAML подставляет значения по умолчанию только для отсутствующих ключей:
+// cleanenv применяет env-default и к явно заданному 0, а 0 в consecutive_failures и error_rate
+// выключает соответствующую проверку
+func (od *OutlierDetection) UnmarshalYAML(value *yaml.Node) error {
+	type plain OutlierDetection
+	p := plain{
+		ConsecutiveFailures: 5,
+		ErrorRate:           0.5,
+		MaxEjectedPercent:   50,
+	}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*od = OutlierDetection(p)
+	return nil
+}
+
+// CircuitBreaker настройки circuit breaker, который создается для каждого бэкенда
+type CircuitBreaker struct {
+	Enabled bool `yaml:"enabled"`
+	// ErrorRatio - доля ошибок (0..1) в окне Window, при которой breaker открывается
+	ErrorRatio float64 `yaml:"error_ratio" env-default:"0.5"`
+	// MinRequests - минимум запросов в окне, чтобы учитывать ErrorRatio
+	MinRequests int           `yaml:"min_requests" env-default:"20"`
+	Window      time.Duration `yaml:"window" env-default:"10s"`
+	// Cooldown - сколько breaker остается открытым перед переходом в half-open
+	Cooldown time.Duration `yaml:"cooldown" env-default:"30s"`
+	// HalfOpenRequests - сколько пробных запросов пропускается в half-open; все должны быть успешными
+	HalfOpenRequests int `yaml:"half_open_requests" env-default:"3"`
 }
 
 type RateLimiter struct {
-	Enabled          bool          `yaml:"enabled"`
-	RateLimiterDb    RateLimiterDb `yaml:"db"`
-	CleanupInterval  time.Duration `yaml:"cleanup_interval"`
-	RefillInterval   time.Duration `yaml:"refill_interval"`
-	BucketExpiration time.Duration `yaml:"bucket_expiration"`
-	Default          struct {
+	Enabled       bool          `yaml:"enabled"`
+	RateLimiterDb RateLimiterDb `yaml:"db"`
+	// CleanupInterval - как часто удалять buckets неактивных клиентов
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1m"`
+	// BucketExpiration - через сколько после последнего запроса клиента его bucket удаляется
+	BucketExpiration time.Duration `yaml:"bucket_expiration" env-default:"1h"`
+	// Algorithm - алгоритм ограничения: token_bucket, sliding_log, sliding_window, fixed_window или gcra
+	Algorithm string `yaml:"algorithm" env-default:"token_bucket"`
+	// LegacyHeaders - дублировать заголовки RateLimit-* устаревшими X-RateLimit-*
+	LegacyHeaders bool `yaml:"legacy_headers"`
+	Default       struct {
 		RefillRate int `yaml:"refill_rate"`
 		MaxTokens  int `yaml:"max_tokens"`
 	} `yaml:"default"`
+	Distributed Distributed `yaml:"distributed"`
+}
+
+// Алгоритмы rate limiter
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingLog    = "sliding_log"
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmFixedWindow   = "fixed_window"
+	AlgorithmGCRA          = "gcra"
+)
+
+// Политики rate limiter при недоступности Redis в распределенном режиме
+const (
+	FallbackLocal = "local"
+	FallbackAllow = "allow"
+	FallbackDeny  = "deny"
+)
+
+// Distributed настройки распределенного режима: состояние buckets хранится в Redis
+// и общее для всех экземпляров балансировщика
+type Distributed struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout - таймаут одного обращения к Redis
+	Timeout time.Duration `yaml:"timeout" env-default:"50ms"`
+	// Fallback - что делать, если Redis недоступен: local (локальные buckets экземпляра),
+	// allow (пропускать все запросы) или deny (отклонять все запросы)
+	Fallback string `yaml:"fallback" env-default:"local"`
+	// RetryInterval - сколько после ошибки не обращаться к Redis и сразу применять fallback
+	RetryInterval time.Duration `yaml:"retry_interval" env-default:"5s"`
 }
 
 type RateLimiterDb struct {
@@ -51,5 +652,250 @@ func LoadConfig(filename string) (*Config, error) {
 	if err := cleanenv.ReadConfig(filename, &cfg); err != nil {
 		return nil, err
 	}
+	// Значения по умолчанию cleanenv проставляет только в полях самой структуры,
+	// поэтому для элементов map их нужно применить отдельно
+	for name, bp := range cfg.Pools {
+		if err := cleanenv.ReadEnv(&bp); err != nil {
+			return nil, err
+		}
+		cfg.Pools[name] = bp
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
+
+// Validate проверяет значения, без которых балансировщик не может работать корректно
+func (c *Config) Validate() error {
+	if err := c.BackendPool.validate("pool"); err != nil {
+		return err
+	}
+	for name, bp := range c.Pools {
+		if name == DefaultPool {
+			return fmt.Errorf("pools: name %q is reserved for the pool block", DefaultPool)
+		}
+		if err := bp.validate("pools." + name); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool, len(c.Routes))
+	for i, rt := range c.Routes {
+		if err := rt.validate(c); err != nil {
+			return fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		if rt.Name != "" && names[rt.Name] {
+			return fmt.Errorf("routes[%d]: duplicate route name %q", i, rt.Name)
+		}
+		names[rt.Name] = true
+	}
+
+	if rt := c.Server.Retry; rt.Attempts < 0 || rt.BudgetPercent < 0 || rt.MinRetriesPerSecond < 0 || rt.MaxBodySize < 0 {
+		return errors.New("server.retry: values must not be negative")
+	}
+
+	if t := c.Server.Timeouts; t.ReadHeader < 0 || t.Read < 0 || t.Write < 0 || t.Idle < 0 || t.Request < 0 {
+		return errors.New("server.timeouts: values must not be negative")
+	}
+	for _, rt := range c.Server.Timeouts.Routes {
+		if rt.PathPrefix == "" || rt.Timeout <= 0 {
+			return errors.New("server.timeouts.routes: path_prefix must be set and timeout must be positive")
+		}
+	}
+
+	if t := c.Tracing; t.Enabled {
+		if t.Protocol != OTLPGRPC && t.Protocol != OTLPHTTP {
+			return fmt.Errorf("tracing.protocol must be %q or %q", OTLPGRPC, OTLPHTTP)
+		}
+		if t.Endpoint == "" || t.SampleRatio < 0 || t.SampleRatio > 1 {
+			return errors.New("tracing: endpoint is required, sample_ratio must be in [0, 1]")
+		}
+	}
+
+	if al := c.AccessLog; al.Enabled {
+		if al.Format != AccessLogJSON && al.Format != AccessLogCombined && al.Format != AccessLogTemplate {
+			return fmt.Errorf("access_log.format must be %q, %q or %q", AccessLogJSON, AccessLogCombined, AccessLogTemplate)
+		}
+		if al.Format == AccessLogTemplate && al.Template == "" {
+			return errors.New("access_log.template is required for format: template")
+		}
+		if al.MaxSizeMB <= 0 || al.MaxBackups < 0 || al.SuccessSampleRate < 0 || al.SuccessSampleRate > 1 {
+			return errors.New("access_log: max_size_mb must be positive, success_sample_rate must be in [0, 1]")
+		}
+	}
+
+	if err := c.Admin.validate(); err != nil {
+		return err
+	}
+
+	if c.RateLimiter.Enabled {
+		if c.RateLimiter.Default.MaxTokens <= 0 || c.RateLimiter.Default.RefillRate <= 0 {
+			return errors.New("rate_limiter.default: max_tokens and refill_rate must be positive")
+		}
+		if c.RateLimiter.CleanupInterval <= 0 || c.RateLimiter.BucketExpiration <= 0 {
+			return errors.New("rate_limiter: cleanup_interval and bucket_expiration must be positive")
+		}
+		switch c.RateLimiter.Algorithm {
+		case AlgorithmTokenBucket, AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmFixedWindow, AlgorithmGCRA:
+		default:
+			return fmt.Errorf("rate_limiter.algorithm: unknown algorithm %q", c.RateLimiter.Algorithm)
+		}
+		if d := c.RateLimiter.Distributed; d.Enabled {
+			// Общие buckets в Redis реализованы только для token bucket
+			if c.RateLimiter.Algorithm != AlgorithmTokenBucket {
+				return fmt.Errorf("rate_limiter.distributed requires algorithm %q", AlgorithmTokenBucket)
+			}
+			if d.Fallback != FallbackLocal && d.Fallback != FallbackAllow && d.Fallback != FallbackDeny {
+				return fmt.Errorf("rate_limiter.distributed.fallback must be %q, %q or %q", FallbackLocal, FallbackAllow, FallbackDeny)
+			}
+			if d.Timeout <= 0 || d.RetryInterval < 0 {
+				return errors.New("rate_limiter.distributed: timeout must be positive, retry_interval must not be negative")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Admin) validate() error {
+	names := make(map[string]struct{}, len(a.Tokens))
+	for _, t := range a.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return errors.New("admin.tokens: name and token are required")
+		}
+		if _, exists := names[t.Name]; exists {
+			return fmt.Errorf("admin.tokens: duplicate name %q", t.Name)
+		}
+		names[t.Name] = struct{}{}
+		if t.Role != AdminRoleRead && t.Role != AdminRoleWrite {
+			return fmt.Errorf("admin.tokens.%s: role must be %q or %q", t.Name, AdminRoleRead, AdminRoleWrite)
+		}
+	}
+
+	tls := a.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		return errors.New("admin.tls: cert_file and key_file must be set together")
+	}
+	if tls.ClientCAFile != "" && tls.CertFile == "" {
+		return errors.New("admin.tls.client_ca_file requires cert_file and key_file")
+	}
+	if (len(tls.Clients) > 0) != (tls.ClientCAFile != "") {
+		return errors.New("admin.tls: client_ca_file and clients must be set together")
+	}
+	for _, cl := range tls.Clients {
+		if cl.CommonName == "" {
+			return errors.New("admin.tls.clients: common_name is required")
+		}
+		if cl.Role != AdminRoleRead && cl.Role != AdminRoleWrite {
+			return fmt.Errorf("admin.tls.clients.%s: role must be %q or %q", cl.CommonName, AdminRoleRead, AdminRoleWrite)
+		}
+	}
+
+	// TLS без клиентских сертификатов и токенов не аутентифицирует клиентов
+	if tls.CertFile != "" && !a.AuthEnabled() {
+		return errors.New("admin.tls requires admin.tokens or admin.tls.client_ca_file")
+	}
+	return nil
+}
+
+// validate проверяет настройки пула; key - путь к пулу в конфиге для сообщений об ошибках
+func (bp *BackendPool) validate(key string) error {
+	entries := bp.Entries()
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: at least one backend is required", key)
+	}
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: invalid backend url %q", key, e.URL)
+		}
+	}
+
+	if hc := bp.HealthCheck; hc.Interval <= 0 || hc.Jitter < 0 || hc.Concurrency <= 0 {
+		return fmt.Errorf("%s.healthcheck: interval and concurrency must be positive, jitter must not be negative", key)
+	}
+	for _, e := range entries {
+		if err := bp.HealthCheck.Merge(e.HealthCheck).Validate(); err != nil {
+			return fmt.Errorf("%s: backend %q: %w", key, e.URL, err)
+		}
+	}
+
+	for _, e := range entries {
+		if t := bp.Timeouts.Merge(e.Timeouts); t.Dial < 0 || t.TLSHandshake < 0 || t.ResponseHeader < 0 {
+			return fmt.Errorf("%s: backend %q: timeouts must not be negative", key, e.URL)
+		}
+	}
+
+	if od := bp.OutlierDetection; od.Enabled {
+		if od.Interval <= 0 || od.BaseEjectionTime <= 0 || od.MaxEjectionTime < od.BaseEjectionTime {
+			return fmt.Errorf("%s.outlier_detection: interval and ejection times must be positive, max_ejection_time >= base_ejection_time", key)
+		}
+		if od.ErrorRate < 0 || od.ErrorRate > 1 || od.MaxEjectedPercent < 0 || od.MaxEjectedPercent > 100 {
+			return fmt.Errorf("%s.outlier_detection: error_rate must be in [0, 1], max_ejected_percent in [0, 100]", key)
+		}
+	}
+
+	if cb := bp.CircuitBreaker; cb.Enabled {
+		if cb.ErrorRatio <= 0 || cb.ErrorRatio > 1 {
+			return fmt.Errorf("%s.circuit_breaker.error_ratio must be in (0, 1]", key)
+		}
+		if cb.Window <= 0 || cb.Cooldown <= 0 || cb.HalfOpenRequests <= 0 {
+			return fmt.Errorf("%s.circuit_breaker: window, cooldown and half_open_requests must be positive", key)
+		}
+	}
+
+	return nil
+}
+
+func (rt *Route) validate(c *Config) error {
+	if _, ok := c.AllPools()[rt.PoolName()]; !ok {
+		return fmt.Errorf("unknown pool %q", rt.Pool)
+	}
+	if rt.PathRegex != "" {
+		if _, err := regexp.Compile(rt.PathRegex); err != nil {
+			return fmt.Errorf("invalid path_regex: %w", err)
+		}
+	}
+	if rt.PathPrefix != "" && !strings.HasPrefix(rt.PathPrefix, "/") {
+		return errors.New("path_prefix must start with /")
+	}
+	if rt.StripPrefix && rt.PathPrefix == "" {
+		return errors.New("strip_prefix requires path_prefix")
+	}
+	if rt.StripPrefix && rt.Rewrite != "" {
+		return errors.New("strip_prefix and rewrite are mutually exclusive")
+	}
+	if rt.Rewrite != "" && rt.PathPrefix == "" && rt.PathRegex == "" {
+		return errors.New("rewrite requires path_prefix or path_regex")
+	}
+	if cn := rt.Canary; cn != nil {
+		if rt.Name == "" {
+			return errors.New("canary requires route name")
+		}
+		if _, ok := c.AllPools()[cn.Pool]; !ok || cn.Pool == rt.PoolName() {
+			return fmt.Errorf("canary: unknown pool %q or the same as the route pool", cn.Pool)
+		}
+		if cn.Weight < 0 || cn.Weight > 100 {
+			return errors.New("canary.weight must be in [0, 100]")
+		}
+		if cn.Sticky != "" && cn.Sticky != CanaryStickyIP && cn.Sticky != CanaryStickyCookie {
+			return fmt.Errorf("canary.sticky must be %q or %q", CanaryStickyIP, CanaryStickyCookie)
+		}
+	}
+	if m := rt.Mirror; m != nil {
+		if _, ok := c.AllPools()[m.Pool]; !ok {
+			return fmt.Errorf("mirror: unknown pool %q", m.Pool)
+		}
+		if m.Percent < 0 || m.Percent > 100 {
+			return errors.New("mirror.percent must be in [0, 100]")
+		}
+		if m.MaxBodySize < 0 || m.Timeout < 0 || m.MaxConcurrent < 0 {
+			return errors.New("mirror: values must not be negative")
+		}
+		if m.Header == "" {
+			return errors.New("mirror.header must not be empty")
+		}
+	}
+	return nil
+}
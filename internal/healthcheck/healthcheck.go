@@ -2,99 +2,375 @@ package healthcheck
 
 import (
 	"context"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 )
 
+// maxBodySize - сколько байт тела ответа читается для проверки expected_body
+const maxBodySize = 64 << 10
+
 type HealthChecker struct {
-	Endpoint   string
-	Timeout    time.Duration
+	cfg        config.HealthCheck
 	httpClient *http.Client
 	logger     logging.ILogger
+
+	// mux защищает cfg и states
+	mux sync.RWMutex
+	// states хранит счетчики успешных/неудачных проверок подряд для порогов healthy/unhealthy
+	states map[*backend.Backend]*probeState
+	// updated сообщает циклу Start, что настройки (интервал, jitter) изменились
+	updated chan struct{}
+}
+
+type probeState struct {
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastOK    bool
 }
 
-func NewHealthChecker(endpoint string, timeout time.Duration, l logging.ILogger) *HealthChecker {
+// ProbeStatus - результат последней активной проверки бэкенда
+type ProbeStatus struct {
+	LastCheck time.Time
+	Healthy   bool
+	// ConsecutiveFailures - число неудачных проверок подряд
+	ConsecutiveFailures int
+}
+
+func NewHealthChecker(cfg config.HealthCheck, l logging.ILogger) *HealthChecker {
 	return &HealthChecker{
-		Endpoint: endpoint,
-		Timeout:  timeout,
+		cfg: cfg,
 
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		logger: l,
+		// Таймаут задается через контекст каждой проверки, так как он может отличаться у бэкендов
+		httpClient: &http.Client{},
+		logger:     l,
+		states:     make(map[*backend.Backend]*probeState),
+		updated:    make(chan struct{}, 1),
 	}
 }
 
+// Update применяет новые настройки проверки; интервал подхватывается запущенным циклом Start
+func (hc *HealthChecker) Update(cfg config.HealthCheck) {
+	hc.mux.Lock()
+	hc.cfg = cfg
+	hc.mux.Unlock()
+
+	select {
+	case hc.updated <- struct{}{}:
+	default:
+	}
+
+	hc.logger.Info("Health check settings updated", map[string]interface{}{
+		"endpoint":    cfg.Endpoint,
+		"timeout":     cfg.Timeout.String(),
+		"interval":    cfg.Interval.String(),
+		"jitter":      cfg.Jitter.String(),
+		"concurrency": cfg.Concurrency,
+	})
+}
+
+// Check выполняет проверку бэкенда и обновляет его состояние с учетом порогов
+// healthy_threshold/unhealthy_threshold. Возвращает результат именно этой проверки.
 func (hc *HealthChecker) Check(ctx context.Context, b *backend.Backend) bool {
-	url := b.URL.String() + hc.Endpoint
+	hc.mux.RLock()
+	settings := hc.cfg.Merge(b.HealthCheck())
+	hc.mux.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var ok bool
+	switch settings.Type {
+	case config.HealthCheckTCP:
+		ok = hc.probeTCP(ctx, b, settings)
+	case config.HealthCheckGRPC:
+		ok = hc.probeGRPC(ctx, b, settings)
+	default:
+		ok = hc.probeHTTP(ctx, b, settings)
+	}
+
+	result := metrics.ResultSuccess
+	if !ok {
+		result = metrics.ResultFailure
+	}
+	metrics.HealthCheckDuration.WithLabelValues(b.URL.String(), result).Observe(time.Since(start).Seconds())
+
+	hc.apply(b, ok, settings)
+
+	return ok
+}
+
+func (hc *HealthChecker) probeHTTP(ctx context.Context, b *backend.Backend, settings config.HealthCheck) bool {
+	target := *b.URL
+	if settings.Port > 0 {
+		target.Host = probeAddress(b, settings)
+	}
+	url := target.String() + settings.Endpoint
 
 	hc.logger.Info("Starting health check for backend", map[string]interface{}{
 		"url": url,
 	})
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	method := settings.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		hc.logger.Error("Failed to create health check request", map[string]interface{}{
 			"url":   url,
 			"error": err.Error(),
 		})
-		b.SetAlive(false)
 		return false
 	}
 
+	for k, v := range settings.Headers {
+		req.Header.Set(k, v)
+	}
+	if settings.Host != "" {
+		req.Host = settings.Host
+	}
+
 	resp, err := hc.httpClient.Do(req)
 	if err != nil {
 		hc.logger.Error("Health check request failed", map[string]interface{}{
 			"url":   url,
 			"error": err.Error(),
 		})
-		b.SetAlive(false)
 		return false
 	}
 	defer resp.Body.Close()
 
-	isAlive := resp.StatusCode == http.StatusOK
-	b.SetAlive(isAlive)
-
-	if isAlive {
-		hc.logger.Info("Health check succeeded", map[string]interface{}{
+	if !expectedStatus(resp.StatusCode, settings) {
+		hc.logger.Warn("Health check failed", map[string]interface{}{
 			"url":         url,
 			"status_code": resp.StatusCode,
 		})
+		return false
+	}
+
+	if settings.ExpectedBody != "" || settings.ExpectedBodyRegex != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			hc.logger.Warn("Failed to read health check response body", map[string]interface{}{
+				"url":   url,
+				"error": err.Error(),
+			})
+			return false
+		}
+
+		if !expectedBody(body, settings) {
+			hc.logger.Warn("Health check failed, unexpected response body", map[string]interface{}{
+				"url":         url,
+				"status_code": resp.StatusCode,
+			})
+			return false
+		}
+	}
+
+	hc.logger.Info("Health check succeeded", map[string]interface{}{
+		"url":         url,
+		"status_code": resp.StatusCode,
+	})
+
+	return true
+}
+
+// apply меняет состояние бэкенда, только когда набрано нужное количество одинаковых результатов подряд
+func (hc *HealthChecker) apply(b *backend.Backend, ok bool, settings config.HealthCheck) {
+	healthy := max(settings.HealthyThreshold, 1)
+	unhealthy := max(settings.UnhealthyThreshold, 1)
+
+	hc.mux.Lock()
+	st, exists := hc.states[b]
+	if !exists {
+		// Первая проверка бэкенда сразу определяет его состояние, без ожидания порогов
+		st = &probeState{}
+		hc.states[b] = st
+		healthy, unhealthy = 1, 1
+	}
+
+	if ok {
+		st.successes++
+		st.failures = 0
 	} else {
-		hc.logger.Warn("Health check failed", map[string]interface{}{
-			"url":         url,
-			"status_code": resp.StatusCode,
+		st.failures++
+		st.successes = 0
+	}
+	st.lastCheck = time.Now()
+	st.lastOK = ok
+	successes, failures := st.successes, st.failures
+	hc.mux.Unlock()
+
+	switch {
+	case ok && !b.IsAlive() && successes >= healthy:
+		b.SetAlive(true)
+		hc.logger.Info("Backend is healthy again", map[string]interface{}{
+			"url":       b.URL.String(),
+			"successes": successes,
+		})
+	case !ok && b.IsAlive() && failures >= unhealthy:
+		b.SetAlive(false)
+		hc.logger.Warn("Backend marked as unhealthy", map[string]interface{}{
+			"url":      b.URL.String(),
+			"failures": failures,
 		})
 	}
+}
+
+// Status возвращает результат последней проверки бэкенда; false, если бэкенд еще не проверялся
+func (hc *HealthChecker) Status(b *backend.Backend) (ProbeStatus, bool) {
+	hc.mux.RLock()
+	defer hc.mux.RUnlock()
+
+	st, ok := hc.states[b]
+	if !ok {
+		return ProbeStatus{}, false
+	}
+	return ProbeStatus{
+		LastCheck:           st.lastCheck,
+		Healthy:             st.lastOK,
+		ConsecutiveFailures: st.failures,
+	}, true
+}
+
+// forget удаляет счетчики бэкендов, которых больше нет в пуле
+func (hc *HealthChecker) forget(backends []*backend.Backend) {
+	present := make(map[*backend.Backend]struct{}, len(backends))
+	for _, b := range backends {
+		present[b] = struct{}{}
+	}
+
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+
+	for b := range hc.states {
+		if _, exists := present[b]; !exists {
+			delete(hc.states, b)
+		}
+	}
+}
+
+func expectedStatus(code int, settings config.HealthCheck) bool {
+	// Настройки уже провалидированы при загрузке конфига
+	ranges, err := settings.StatusRanges()
+	if err != nil {
+		return false
+	}
+
+	for _, r := range ranges {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func expectedBody(body []byte, settings config.HealthCheck) bool {
+	if settings.ExpectedBody != "" && !strings.Contains(string(body), settings.ExpectedBody) {
+		return false
+	}
 
-	return isAlive
+	if settings.ExpectedBodyRegex != "" {
+		re, err := regexp.Compile(settings.ExpectedBodyRegex)
+		if err != nil || !re.Match(body) {
+			return false
+		}
+	}
+
+	return true
 }
 
-func (hc *HealthChecker) Start(ctx context.Context, backends []*backend.Backend, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// Start периодически проверяет бэкенды пула. Список берется из пула на каждом цикле,
+// поэтому добавленные и удаленные через admin API бэкенды учитываются автоматически
+func (hc *HealthChecker) Start(ctx context.Context, pool *backend.Pool) {
+	timer := time.NewTimer(hc.nextDelay())
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			hc.logger.Debug("Running health check cycle", map[string]interface{}{
-				"backends": len(backends),
-			})
-			for _, b := range backends {
-				hc.Check(ctx, b)
+		case <-timer.C:
+			hc.RunCycle(ctx, pool)
+			timer.Reset(hc.nextDelay())
+		case <-hc.updated:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
 			}
-			hc.logger.Debug("Completed health check cycle", map[string]interface{}{
-				"backends": len(backends),
-			})
+			timer.Reset(hc.nextDelay())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunCycle проверяет все бэкенды пула параллельно (не больше concurrency одновременно)
+// и возвращается, когда все проверки завершены
+func (hc *HealthChecker) RunCycle(ctx context.Context, pool *backend.Pool) {
+	hc.mux.RLock()
+	concurrency := max(hc.cfg.Concurrency, 1)
+	hc.mux.RUnlock()
+
+	backends := pool.List()
+	hc.logger.Debug("Running health check cycle", map[string]interface{}{
+		"backends":    len(backends),
+		"concurrency": concurrency,
+	})
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, b := range backends {
+		select {
+		case sem <- struct{}{}:
 		case <-ctx.Done():
+			wg.Wait()
 			return
 		}
+
+		wg.Add(1)
+		go func(b *backend.Backend) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			hc.Check(ctx, b)
+		}(b)
 	}
+	wg.Wait()
+
+	hc.forget(backends)
+	hc.logger.Debug("Completed health check cycle", map[string]interface{}{
+		"backends": len(backends),
+	})
 }
+
+// nextDelay возвращает интервал до следующего цикла со случайной добавкой в пределах jitter
+func (hc *HealthChecker) nextDelay() time.Duration {
+	hc.mux.RLock()
+	defer hc.mux.RUnlock()
+
+	delay := hc.cfg.Interval
+	if hc.cfg.Jitter > 0 {
+		delay += rand.N(hc.cfg.Jitter)
+	}
+
+	return delay
+}
+
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
@@ -0,0 +1,174 @@
+package healthcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+func newTestChecker(cfg config.HealthCheck) *HealthChecker {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	return NewHealthChecker(cfg, logging.NewZeroLoggerWithWriter(3, io.Discard))
+}
+
+func newTestBackend(t *testing.T, rawURL string) *backend.Backend {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse backend url %q: %v", rawURL, err)
+	}
+	b := &backend.Backend{URL: u, Weight: 1}
+	b.SetAlive(true)
+	return b
+}
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"status":"ok","version":"1.4.2"}`))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/redirect":
+			w.WriteHeader(http.StatusFound)
+		case "/host":
+			w.Write([]byte(r.Host))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"down"}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		settings config.HealthCheck
+		want     bool
+	}{
+		{
+			name:     "default status 200",
+			settings: config.HealthCheck{Endpoint: "/ok"},
+			want:     true,
+		},
+		{
+			name:     "default status rejects 201",
+			settings: config.HealthCheck{Endpoint: "/created"},
+			want:     false,
+		},
+		{
+			name:     "range 200-299 accepts 201",
+			settings: config.HealthCheck{Endpoint: "/created", ExpectedStatuses: []string{"200-299"}},
+			want:     true,
+		},
+		{
+			name:     "range 200-299 rejects 302",
+			settings: config.HealthCheck{Endpoint: "/redirect", ExpectedStatuses: []string{"200-299"}},
+			want:     false,
+		},
+		{
+			name:     "exact code and range",
+			settings: config.HealthCheck{Endpoint: "/redirect", ExpectedStatuses: []string{"200-299", "302"}},
+			want:     true,
+		},
+		{
+			name:     "503 is not expected",
+			settings: config.HealthCheck{Endpoint: "/down", ExpectedStatuses: []string{"200-399"}},
+			want:     false,
+		},
+		{
+			name:     "body substring",
+			settings: config.HealthCheck{Endpoint: "/ok", ExpectedBody: `"status":"ok"`},
+			want:     true,
+		},
+		{
+			name:     "body regex matches",
+			settings: config.HealthCheck{Endpoint: "/ok", ExpectedBodyRegex: `"version":"1\.\d+\.\d+"`},
+			want:     true,
+		},
+		{
+			name:     "body regex does not match",
+			settings: config.HealthCheck{Endpoint: "/ok", ExpectedBodyRegex: `"version":"2\.`},
+			want:     false,
+		},
+		{
+			name:     "body regex with unexpected status",
+			settings: config.HealthCheck{Endpoint: "/down", ExpectedStatuses: []string{"200-599"}, ExpectedBodyRegex: `"status":"ok"`},
+			want:     false,
+		},
+		{
+			name:     "host override",
+			settings: config.HealthCheck{Endpoint: "/host", Host: "health.internal", ExpectedBody: "health.internal"},
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := newTestChecker(tt.settings)
+			b := newTestBackend(t, srv.URL)
+
+			if got := hc.Check(context.Background(), b); got != tt.want {
+				t.Fatalf("Check() = %v, want %v", got, tt.want)
+			}
+			// Первая проверка сразу определяет состояние бэкенда
+			if b.IsAlive() != tt.want {
+				t.Fatalf("IsAlive() = %v, want %v", b.IsAlive(), tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeHTTPConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+
+	hc := newTestChecker(config.HealthCheck{Endpoint: "/"})
+	if hc.Check(context.Background(), newTestBackend(t, addr)) {
+		t.Fatal("Check() = true for closed server")
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hc := newTestChecker(config.HealthCheck{Endpoint: "/", HealthyThreshold: 2, UnhealthyThreshold: 2})
+	b := newTestBackend(t, srv.URL)
+	ctx := context.Background()
+
+	steps := []struct {
+		healthy bool
+		alive   bool
+	}{
+		{healthy: true, alive: true},
+		{healthy: false, alive: true},
+		{healthy: false, alive: false},
+		{healthy: true, alive: false},
+		{healthy: true, alive: true},
+	}
+	for i, step := range steps {
+		healthy.Store(step.healthy)
+		hc.Check(ctx, b)
+		if b.IsAlive() != step.alive {
+			t.Fatalf("step %d: IsAlive() = %v, want %v", i, b.IsAlive(), step.alive)
+		}
+	}
+}
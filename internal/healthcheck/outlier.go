@@ -0,0 +1,161 @@
+package healthcheck
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// OutlierDetector - пассивная проверка бэкендов по результатам проксирования.
+// Бэкенд исключается после N ошибок подряд или при превышении доли ошибок за интервал;
+// время исключения растет экспоненциально при повторных исключениях.
+type OutlierDetector struct {
+	cfg    config.OutlierDetection
+	pool   *backend.Pool
+	stats  map[*backend.Backend]*outlierStats
+	mux    sync.Mutex
+	logger logging.ILogger
+}
+
+type outlierStats struct {
+	consecutiveFailures int
+	requests            int
+	failures            int
+	windowStart         time.Time
+	// ejections - сколько раз подряд бэкенд исключался; сбрасывается, если исключений не было дольше 2*MaxEjectionTime
+	ejections   int
+	lastEjectAt time.Time
+}
+
+func NewOutlierDetector(cfg config.OutlierDetection, pool *backend.Pool, l logging.ILogger) *OutlierDetector {
+	return &OutlierDetector{
+		cfg:    cfg,
+		pool:   pool,
+		stats:  make(map[*backend.Backend]*outlierStats),
+		logger: l,
+	}
+}
+
+// Update применяет новые настройки; накопленная статистика сохраняется
+func (od *OutlierDetector) Update(cfg config.OutlierDetection) {
+	od.mux.Lock()
+	defer od.mux.Unlock()
+
+	od.cfg = cfg
+}
+
+// Observe учитывает результат запроса к бэкенду: success=false для ошибок соединения и ответов 5xx
+func (od *OutlierDetector) Observe(b *backend.Backend, success bool) {
+	od.mux.Lock()
+	defer od.mux.Unlock()
+
+	if !od.cfg.Enabled {
+		return
+	}
+
+	now := time.Now()
+
+	st, exists := od.stats[b]
+	if !exists {
+		st = &outlierStats{windowStart: now}
+		od.stats[b] = st
+		// Бэкенды, удаленные через admin API или пересозданные при reload, в пуле больше не встречаются
+		if len(od.stats) > od.pool.GetBackendsLength() {
+			od.forget(od.pool.List())
+		}
+	}
+
+	if now.Sub(st.windowStart) > od.cfg.Interval {
+		st.requests, st.failures, st.windowStart = 0, 0, now
+	}
+	if st.ejections > 0 && now.Sub(st.lastEjectAt) > od.cfg.MaxEjectionTime*2 {
+		st.ejections = 0
+	}
+
+	st.requests++
+	if success {
+		st.consecutiveFailures = 0
+		return
+	}
+	st.failures++
+	st.consecutiveFailures++
+
+	if b.IsEjected() {
+		return
+	}
+
+	reason := ""
+	switch {
+	case od.cfg.ConsecutiveFailures > 0 && st.consecutiveFailures >= od.cfg.ConsecutiveFailures:
+		reason = "consecutive_failures"
+	case od.cfg.ErrorRate > 0 && st.requests >= od.cfg.MinRequests &&
+		float64(st.failures)/float64(st.requests) >= od.cfg.ErrorRate:
+		reason = "error_rate"
+	default:
+		return
+	}
+
+	if !od.canEject() {
+		od.logger.Warn("Outlier detected, but max ejected percent reached", map[string]interface{}{
+			"url":                 b.URL.String(),
+			"reason":              reason,
+			"max_ejected_percent": od.cfg.MaxEjectedPercent,
+		})
+		return
+	}
+
+	duration := od.cfg.BaseEjectionTime << st.ejections
+	if duration > od.cfg.MaxEjectionTime || duration <= 0 {
+		duration = od.cfg.MaxEjectionTime
+	}
+
+	b.Eject(now.Add(duration))
+
+	od.logger.Warn("Backend ejected by outlier detection", map[string]interface{}{
+		"url":                  b.URL.String(),
+		"reason":               reason,
+		"consecutive_failures": st.consecutiveFailures,
+		"requests":             st.requests,
+		"failures":             st.failures,
+		"ejection_time":        duration.String(),
+	})
+
+	st.ejections++
+	st.lastEjectAt = now
+	st.consecutiveFailures = 0
+	st.requests, st.failures, st.windowStart = 0, 0, now
+}
+
+// canEject проверяет, что после исключения еще одного бэкенда доля исключенных не превысит MaxEjectedPercent
+func (od *OutlierDetector) canEject() bool {
+	backends := od.pool.List()
+	if len(backends) == 0 {
+		return false
+	}
+
+	ejected := 0
+	for _, b := range backends {
+		if b.IsEjected() {
+			ejected++
+		}
+	}
+
+	return (ejected+1)*100 <= od.cfg.MaxEjectedPercent*len(backends)
+}
+
+// forget удаляет статистику бэкендов, которых больше нет в пуле; вызывается под od.mux
+func (od *OutlierDetector) forget(backends []*backend.Backend) {
+	present := make(map[*backend.Backend]struct{}, len(backends))
+	for _, b := range backends {
+		present[b] = struct{}{}
+	}
+
+	for b := range od.stats {
+		if _, exists := present[b]; !exists {
+			delete(od.stats, b)
+		}
+	}
+}
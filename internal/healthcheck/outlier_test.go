@@ -0,0 +1,84 @@
+package healthcheck
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+func newTestOutlierDetector(t *testing.T, cfg config.OutlierDetection, urls ...string) (*OutlierDetector, *backend.Pool) {
+	t.Helper()
+
+	l := logging.NewZeroLoggerWithWriter(4, io.Discard)
+	pool := backend.NewPool(config.DefaultPool, config.BackendPool{URLs: urls}, l)
+	return NewOutlierDetector(cfg, pool, l), pool
+}
+
+func TestOutlierDetectionExplicitZeros(t *testing.T) {
+	const timing = `
+enabled: true
+min_requests: 20
+interval: 10s
+base_ejection_time: 30s
+max_ejection_time: 5m
+`
+
+	tests := []struct {
+		name  string
+		yaml  string
+		eject bool
+	}{
+		// По умолчанию бэкенд исключается после 5 ошибок подряд
+		{name: "defaults", yaml: timing, eject: true},
+		// 0 выключает проверку ошибок подряд, а для error_rate 5 запросов меньше min_requests
+		{name: "consecutive_failures 0", yaml: timing + "consecutive_failures: 0\n", eject: false},
+		// 0 запрещает исключать бэкенды совсем
+		{name: "max_ejected_percent 0", yaml: timing + "max_ejected_percent: 0\n", eject: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.OutlierDetection
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			od, pool := newTestOutlierDetector(t, cfg, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+			b := pool.List()[0]
+
+			for i := 0; i < 5; i++ {
+				od.Observe(b, false)
+			}
+			if b.IsEjected() != tt.eject {
+				t.Fatalf("ejected = %v after 5 failures, want %v", b.IsEjected(), tt.eject)
+			}
+		})
+	}
+}
+
+func TestOutlierDetectorForgetsRemovedBackends(t *testing.T) {
+	cfg := config.OutlierDetection{Enabled: true, ConsecutiveFailures: 5, MinRequests: 20, Interval: time.Minute}
+	od, pool := newTestOutlierDetector(t, cfg, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	backends := pool.List()
+	for _, b := range backends {
+		od.Observe(b, false)
+	}
+
+	// Бэкенд заменен через admin API: статистика удаленного не должна копиться
+	if _, err := pool.Remove(backends[0].URL.String()); err != nil {
+		t.Fatal(err)
+	}
+	added, err := pool.Add(config.Backend{URL: "http://127.0.0.1:8083"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	od.Observe(added, false)
+
+	if _, exists := od.stats[backends[0]]; exists || len(od.stats) != 2 {
+		t.Fatalf("stats for %d backends, removed one kept: %v; want only the 2 pool backends", len(od.stats), exists)
+	}
+}
@@ -0,0 +1,111 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// probeTCP считает бэкенд живым, если к нему удалось установить TCP-соединение
+func (hc *HealthChecker) probeTCP(ctx context.Context, b *backend.Backend, settings config.HealthCheck) bool {
+	addr := probeAddress(b, settings)
+
+	hc.logger.Info("Starting tcp health check for backend", map[string]interface{}{
+		"address": addr,
+	})
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		hc.logger.Error("Health check connection failed", map[string]interface{}{
+			"address": addr,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	conn.Close()
+
+	hc.logger.Info("Health check succeeded", map[string]interface{}{
+		"address": addr,
+	})
+
+	return true
+}
+
+// probeGRPC вызывает стандартный grpc.health.v1.Health/Check и ожидает статус SERVING
+func (hc *HealthChecker) probeGRPC(ctx context.Context, b *backend.Backend, settings config.HealthCheck) bool {
+	addr := probeAddress(b, settings)
+
+	hc.logger.Info("Starting grpc health check for backend", map[string]interface{}{
+		"address": addr,
+		"service": settings.GRPCService,
+	})
+
+	creds := insecure.NewCredentials()
+	if b.URL.Scheme == "https" {
+		creds = credentials.NewTLS(&tls.Config{ServerName: b.URL.Hostname()})
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		hc.logger.Error("Failed to create grpc health check client", map[string]interface{}{
+			"address": addr,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: settings.GRPCService,
+	})
+	if err != nil {
+		hc.logger.Error("Health check request failed", map[string]interface{}{
+			"address": addr,
+			"service": settings.GRPCService,
+			"error":   err.Error(),
+		})
+		return false
+	}
+
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		hc.logger.Warn("Health check failed", map[string]interface{}{
+			"address": addr,
+			"service": settings.GRPCService,
+			"status":  resp.GetStatus().String(),
+		})
+		return false
+	}
+
+	hc.logger.Info("Health check succeeded", map[string]interface{}{
+		"address": addr,
+		"service": settings.GRPCService,
+	})
+
+	return true
+}
+
+// probeAddress возвращает host:port для проверки с учетом переопределения порта
+func probeAddress(b *backend.Backend, settings config.HealthCheck) string {
+	host, port := b.URL.Hostname(), b.URL.Port()
+
+	if settings.Port > 0 {
+		port = strconv.Itoa(settings.Port)
+	}
+	if port == "" {
+		port = "80"
+		if b.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(host, port)
+}
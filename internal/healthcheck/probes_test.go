@@ -0,0 +1,155 @@
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name     string
+		url      string
+		settings config.HealthCheck
+		want     bool
+	}{
+		{
+			name: "open port",
+			url:  "http://127.0.0.1:" + strconv.Itoa(port),
+			want: true,
+		},
+		{
+			name: "closed port",
+			url:  "http://127.0.0.1:" + strconv.Itoa(closedPort),
+			want: false,
+		},
+		{
+			name:     "port override",
+			url:      "http://127.0.0.1:" + strconv.Itoa(closedPort),
+			settings: config.HealthCheck{Port: port},
+			want:     true,
+		},
+		{
+			name:     "port override to closed port",
+			url:      "http://127.0.0.1:" + strconv.Itoa(port),
+			settings: config.HealthCheck{Port: closedPort},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.settings.Type = config.HealthCheckTCP
+			hc := newTestChecker(tt.settings)
+
+			if got := hc.Check(context.Background(), newTestBackend(t, tt.url)); got != tt.want {
+				t.Fatalf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeGRPC(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	hs := health.NewServer()
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("billing", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	addr := "http://" + ln.Addr().String()
+
+	tests := []struct {
+		name    string
+		service string
+		// overall - статус сервера целиком (пустое имя сервиса)
+		overall healthpb.HealthCheckResponse_ServingStatus
+		want    bool
+	}{
+		{
+			name:    "server serving",
+			overall: healthpb.HealthCheckResponse_SERVING,
+			want:    true,
+		},
+		{
+			name:    "server not serving",
+			overall: healthpb.HealthCheckResponse_NOT_SERVING,
+			want:    false,
+		},
+		{
+			name:    "service serving",
+			service: "orders",
+			overall: healthpb.HealthCheckResponse_NOT_SERVING,
+			want:    true,
+		},
+		{
+			name:    "service not serving",
+			service: "billing",
+			overall: healthpb.HealthCheckResponse_SERVING,
+			want:    false,
+		},
+		{
+			name:    "unknown service",
+			service: "inventory",
+			overall: healthpb.HealthCheckResponse_SERVING,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs.SetServingStatus("", tt.overall)
+			hc := newTestChecker(config.HealthCheck{Type: config.HealthCheckGRPC, GRPCService: tt.service})
+
+			if got := hc.Check(context.Background(), newTestBackend(t, addr)); got != tt.want {
+				t.Fatalf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("stopped server", func(t *testing.T) {
+		srv.Stop()
+		hc := newTestChecker(config.HealthCheck{Type: config.HealthCheckGRPC})
+
+		if hc.Check(context.Background(), newTestBackend(t, addr)) {
+			t.Fatal("Check() = true for stopped server")
+		}
+	})
+}
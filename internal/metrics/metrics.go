@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lb"
+
+// Метрики, которые обновляются по событиям. Состояние пулов (соединения, alive)
+// и число buckets снимаются в момент сбора через Collector'ы в пакете server
+var (
+	// Requests - число проксированных запросов по пулу, бэкенду, HTTP-методу и коду ответа
+	Requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Proxied requests by pool, backend, method and status.",
+	}, []string{"pool", "backend", "method", "status"})
+
+	// RequestDuration - длительность проксирования запросов на бэкенд
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of proxied requests by pool, backend, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"pool", "backend", "method", "status"})
+
+	// HealthCheckDuration - длительность активных проверок по бэкенду и результату (success, failure)
+	HealthCheckDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "healthcheck_duration_seconds",
+		Help:      "Duration of active health check probes by backend and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "result"})
+
+	// RateLimiterRequests - решения rate limiter по результату (allowed, rejected)
+	RateLimiterRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimiter",
+		Name:      "requests_total",
+		Help:      "Rate limiter decisions by result.",
+	}, []string{"result"})
+
+	// RedisErrors - ошибки операций репозитория настроек rate limiter в Redis
+	RedisErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "errors_total",
+		Help:      "Failed Redis repository operations by operation.",
+	}, []string{"operation"})
+)
+
+// Результаты для меток result
+const (
+	ResultSuccess  = "success"
+	ResultFailure  = "failure"
+	ResultAllowed  = "allowed"
+	ResultRejected = "rejected"
+)
+
+// DeleteBackend удаляет серии бэкенда, удаленного из пула pool, чтобы они не копились при смене бэкендов.
+// Метки method и status у серий разные, поэтому удаляются все серии с этими pool и backend
+func DeleteBackend(pool, backend string) {
+	Requests.DeletePartialMatch(prometheus.Labels{"pool": pool, "backend": backend})
+	RequestDuration.DeletePartialMatch(prometheus.Labels{"pool": pool, "backend": backend})
+	HealthCheckDuration.DeletePartialMatch(prometheus.Labels{"backend": backend})
+}
+
+// Register регистрирует дополнительный Collector в общем реестре
+func Register(c prometheus.Collector) error {
+	return prometheus.Register(c)
+}
+
+// Handler отдает метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
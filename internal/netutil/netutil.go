@@ -0,0 +1,21 @@
+// Package netutil содержит общие для балансировщика и rate limiter функции работы с адресами клиентов
+package netutil
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP извлекает IP-адрес клиента: первый адрес из X-Forwarded-For или адрес соединения
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ips := strings.Split(forwarded, ",")
+		return strings.TrimSpace(ips[0])
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
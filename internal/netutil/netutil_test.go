@@ -0,0 +1,33 @@
+package netutil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "remote addr", remoteAddr: "203.0.113.7:52344", want: "203.0.113.7"},
+		{name: "ipv6 remote addr", remoteAddr: "[2001:db8::1]:52344", want: "2001:db8::1"},
+		{name: "remote addr without port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+		{name: "first forwarded address", remoteAddr: "10.0.0.1:80", forwarded: " 198.51.100.2 , 10.0.0.5", want: "198.51.100.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Fatalf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package ratelimiter
+
+import (
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+// algorithm - состояние лимита одного клиента. Все алгоритмы используют настройки клиента одинаково:
+// max_tokens - сколько запросов можно сделать подряд (размер окна), refill_rate - средняя скорость в секунду.
+// Для оконных алгоритмов длина окна равна max_tokens / refill_rate
+type algorithm interface {
+	// take учитывает запрос в момент now и возвращает решение; Limit заполняет вызывающий
+	take(now time.Time) Result
+}
+
+// Result - решение rate limiter и состояние лимита клиента для заголовков RateLimit-*
+type Result struct {
+	Allowed bool
+	// Limit - max_tokens клиента; 0, если лимит не считался (rate limiter выключен или сработал fallback)
+	Limit int
+	// Remaining - сколько запросов клиент может сделать прямо сейчас
+	Remaining int
+	// Reset - через сколько лимит восстановится полностью
+	Reset time.Duration
+	// RetryAfter - через сколько будет пропущен следующий запрос отклоненного клиента
+	RetryAfter time.Duration
+}
+
+// TokenBucketResult строит Result по числу токенов, оставшихся в bucket после запроса
+func TokenBucketResult(allowed bool, tokens float64, cfg Config) Result {
+	rate := float64(cfg.RefillRate)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     cfg.MaxTokens,
+		Remaining: int(tokens),
+		Reset:     seconds((float64(cfg.MaxTokens) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(max(s, 0) * float64(time.Second))
+}
+
+func newAlgorithm(name string, cfg Config, now time.Time) algorithm {
+	limit := cfg.MaxTokens
+	window := time.Duration(float64(cfg.MaxTokens) / float64(cfg.RefillRate) * float64(time.Second))
+
+	switch name {
+	case config.AlgorithmSlidingLog:
+		return &slidingLog{limit: limit, window: window}
+	case config.AlgorithmSlidingWindow:
+		return &slidingWindow{limit: limit, window: window, start: now}
+	case config.AlgorithmFixedWindow:
+		return &fixedWindow{limit: limit, window: window, start: now}
+	case config.AlgorithmGCRA:
+		interval := time.Duration(float64(time.Second) / float64(cfg.RefillRate))
+		return &gcra{
+			interval:  interval,
+			tolerance: interval * time.Duration(limit-1),
+			tat:       now,
+		}
+	default:
+		return &tokenBucket{tokens: float64(cfg.MaxTokens), config: cfg, lastRefill: now}
+	}
+}
+
+// tokenBucket - bucket на max_tokens токенов, который пополняется со скоростью refill_rate.
+// Токены начисляются при каждом запросе за время, прошедшее с lastRefill
+type tokenBucket struct {
+	tokens     float64
+	config     Config
+	lastRefill time.Time
+}
+
+func (b *tokenBucket) take(now time.Time) Result {
+	if elapsed := now.Sub(b.lastRefill); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*float64(b.config.RefillRate), float64(b.config.MaxTokens))
+		b.lastRefill = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return TokenBucketResult(allowed, b.tokens, b.config)
+}
+
+// slidingLog хранит время каждого принятого запроса за последнее окно. Точный, но память
+// растет с лимитом: до max_tokens отметок на клиента
+type slidingLog struct {
+	limit  int
+	window time.Duration
+	log    []time.Time
+}
+
+func (s *slidingLog) take(now time.Time) Result {
+	cutoff := now.Add(-s.window)
+	expired := 0
+	for expired < len(s.log) && !s.log[expired].After(cutoff) {
+		expired++
+	}
+	s.log = s.log[expired:]
+
+	res := Result{Allowed: len(s.log) < s.limit}
+	if res.Allowed {
+		s.log = append(s.log, now)
+	} else {
+		// Следующий запрос пройдет, когда из окна выйдет самый старый
+		res.RetryAfter = s.log[0].Add(s.window).Sub(now)
+	}
+	res.Remaining = s.limit - len(s.log)
+	if len(s.log) > 0 {
+		res.Reset = s.log[len(s.log)-1].Add(s.window).Sub(now)
+	}
+	return res
+}
+
+// slidingWindow приближает скользящее окно по счетчикам текущего и предыдущего окна:
+// вклад предыдущего окна убывает линейно по мере продвижения текущего
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+	start  time.Time
+	prev   int
+	curr   int
+}
+
+func (s *slidingWindow) take(now time.Time) Result {
+	if elapsed := now.Sub(s.start); elapsed >= s.window {
+		windows := elapsed / s.window
+		s.prev = s.curr
+		if windows > 1 {
+			s.prev = 0
+		}
+		s.curr = 0
+		s.start = s.start.Add(windows * s.window)
+	}
+
+	elapsed := now.Sub(s.start)
+	weight := 1 - float64(elapsed)/float64(s.window)
+	res := Result{Allowed: float64(s.prev)*weight+float64(s.curr) < float64(s.limit)}
+	if res.Allowed {
+		s.curr++
+	} else {
+		res.RetryAfter = s.retryAfter(elapsed)
+	}
+
+	res.Remaining = max(int(float64(s.limit)-float64(s.prev)*weight-float64(s.curr)), 0)
+	switch {
+	case s.curr > 0:
+		res.Reset = 2*s.window - elapsed
+	case s.prev > 0:
+		res.Reset = s.window - elapsed
+	}
+	return res
+}
+
+// retryAfter - через сколько оценка числа запросов в скользящем окне опустится ниже лимита.
+// Формулы дают момент, когда оценка равна лимиту и запрос еще отклоняется, поэтому добавляется 1ns
+func (s *slidingWindow) retryAfter(elapsed time.Duration) time.Duration {
+	window := float64(s.window)
+	if s.curr < s.limit {
+		// Хватит того, что уменьшится вклад предыдущего окна
+		return seconds((window*(1-float64(s.limit-s.curr)/float64(s.prev))-float64(elapsed))/float64(time.Second)) + time.Nanosecond
+	}
+	// Текущее окно заполнено: ждем его конца и уменьшения его вклада в следующем
+	return seconds((window-float64(elapsed)+window*(1-float64(s.limit)/float64(s.curr)))/float64(time.Second)) + time.Nanosecond
+}
+
+// fixedWindow считает запросы в окнах фиксированной длины; на стыке окон
+// клиент может сделать до 2 * max_tokens запросов подряд
+type fixedWindow struct {
+	limit  int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func (f *fixedWindow) take(now time.Time) Result {
+	if elapsed := now.Sub(f.start); elapsed >= f.window {
+		f.start = f.start.Add(elapsed / f.window * f.window)
+		f.count = 0
+	}
+
+	res := Result{Allowed: f.count < f.limit}
+	if res.Allowed {
+		f.count++
+	}
+	res.Remaining = f.limit - f.count
+	res.Reset = f.start.Add(f.window).Sub(now)
+	if !res.Allowed {
+		res.RetryAfter = res.Reset
+	}
+	return res
+}
+
+// gcra (generic cell rate algorithm) хранит только теоретическое время прихода следующего запроса (TAT):
+// запросы идут с интервалом 1 / refill_rate, допускается опережение на max_tokens - 1 интервалов
+type gcra struct {
+	interval  time.Duration
+	tolerance time.Duration
+	tat       time.Time
+}
+
+func (g *gcra) take(now time.Time) Result {
+	tat := g.tat
+	if now.After(tat) {
+		tat = now
+	}
+
+	res := Result{Allowed: tat.Sub(now) <= g.tolerance}
+	if res.Allowed {
+		g.tat = tat.Add(g.interval)
+	} else {
+		res.RetryAfter = tat.Sub(now) - g.tolerance
+	}
+
+	// Запрос пройдет, пока TAT опережает текущее время не больше чем на tolerance
+	if ahead := g.tat.Sub(now); ahead <= g.tolerance {
+		res.Remaining = int((g.tolerance-ahead)/g.interval) + 1
+	}
+	res.Reset = max(g.tat.Sub(now), 0)
+	return res
+}
@@ -0,0 +1,187 @@
+package ratelimiter
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/clock"
+	"github.com/dielit66/cloud-camp-tt/internal/clock/clocktest"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+var testStart = clocktest.Start
+
+// stubRepository - репозиторий без персональных настроек: все клиенты получают настройки по умолчанию
+type stubRepository struct{}
+
+func (stubRepository) GetConfig(ctx context.Context, ip string) (Config, error) {
+	return Config{}, ErrConfigNotFound
+}
+
+func (stubRepository) SetConfig(ctx context.Context, ip string, config Config) error {
+	return nil
+}
+
+func (stubRepository) DeleteConfig(ctx context.Context, ip string) error {
+	return nil
+}
+
+func newTestRateLimiter(t *testing.T, algorithm string, defaults Config, clock clock.Clock) *RateLimiter {
+	t.Helper()
+
+	cfg := &config.RateLimiter{
+		Enabled:          true,
+		CleanupInterval:  time.Hour,
+		BucketExpiration: time.Hour,
+		Algorithm:        algorithm,
+	}
+	cfg.Default.MaxTokens = defaults.MaxTokens
+	cfg.Default.RefillRate = defaults.RefillRate
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewRateLimiter(ctx, stubRepository{}, nil, logging.NewZeroLoggerWithWriter(4, io.Discard), cfg, WithClock(clock))
+}
+
+// step - запрос в момент testStart + at и ожидаемое решение
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestAlgorithms(t *testing.T) {
+	// max_tokens 4, refill_rate 2: окно 2s, интервал GCRA 500ms
+	defaults := Config{MaxTokens: 4, RefillRate: 2}
+
+	tests := []struct {
+		algorithm string
+		steps     []step
+	}{
+		{
+			algorithm: config.AlgorithmTokenBucket,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 0, retryAfter: 500 * time.Millisecond},
+				// Пополнено полтокена: до целого не хватает 250ms
+				{at: 250 * time.Millisecond, retryAfter: 250 * time.Millisecond},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 0},
+				{at: 750 * time.Millisecond, retryAfter: 250 * time.Millisecond},
+				// Bucket не наполняется выше max_tokens
+				{at: 10 * time.Second, allowed: true, remaining: 3},
+			},
+		},
+		{
+			algorithm: config.AlgorithmSlidingLog,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 2},
+				{at: time.Second, allowed: true, remaining: 1},
+				{at: 1500 * time.Millisecond, allowed: true, remaining: 0},
+				// Самый старый запрос выйдет из окна в 2s
+				{at: 1500 * time.Millisecond, retryAfter: 500 * time.Millisecond},
+				{at: 2 * time.Second, allowed: true, remaining: 0},
+				{at: 2250 * time.Millisecond, retryAfter: 250 * time.Millisecond},
+			},
+		},
+		{
+			algorithm: config.AlgorithmSlidingWindow,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				// Текущее окно заполнено: ждем его конца (1.5s). В начале следующего вклад 4 запросов
+				// ровно равен лимиту, поэтому запрос пройдет на 1ns позже
+				{at: 500 * time.Millisecond, retryAfter: 1500*time.Millisecond + time.Nanosecond},
+				{at: 2 * time.Second, retryAfter: time.Nanosecond},
+				// Вклад предыдущего окна 4 * 0.875 = 3.5
+				{at: 2250 * time.Millisecond, allowed: true, remaining: 0},
+				// 3.5 + 1: вклад предыдущего должен опуститься ниже 3, то есть чуть позже 250ms
+				{at: 2250 * time.Millisecond, retryAfter: 250*time.Millisecond + time.Nanosecond},
+				{at: 2500 * time.Millisecond, retryAfter: time.Nanosecond},
+				{at: 2500*time.Millisecond + time.Nanosecond, allowed: true, remaining: 0},
+			},
+		},
+		{
+			algorithm: config.AlgorithmFixedWindow,
+			steps: []step{
+				// Окно [0, 2s) начинается с первого запроса
+				{at: 0, allowed: true, remaining: 3},
+				{at: 1900 * time.Millisecond, allowed: true, remaining: 2},
+				{at: 1900 * time.Millisecond, allowed: true, remaining: 1},
+				{at: 1900 * time.Millisecond, allowed: true, remaining: 0},
+				{at: 1900 * time.Millisecond, retryAfter: 100 * time.Millisecond},
+				// На стыке окон за 100ms проходит 2 * max_tokens - 1 запросов
+				{at: 2 * time.Second, allowed: true, remaining: 3},
+				{at: 2 * time.Second, allowed: true, remaining: 2},
+				{at: 2 * time.Second, allowed: true, remaining: 1},
+				{at: 2 * time.Second, allowed: true, remaining: 0},
+				{at: 2 * time.Second, retryAfter: 2 * time.Second},
+			},
+		},
+		{
+			algorithm: config.AlgorithmGCRA,
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				// TAT = 2s опережает время на 2s, допускается 1.5s
+				{at: 0, retryAfter: 500 * time.Millisecond},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 0},
+				{at: 500 * time.Millisecond, retryAfter: 500 * time.Millisecond},
+				// TAT отстал от времени: клиент снова может сделать max_tokens запросов подряд
+				{at: 3 * time.Second, allowed: true, remaining: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			clock := clocktest.NewFake()
+			rl := newTestRateLimiter(t, tt.algorithm, defaults, clock)
+
+			for i, s := range tt.steps {
+				clock.Set(testStart.Add(s.at))
+				res := rl.Allow(context.Background(), "10.0.0.1")
+
+				if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter {
+					t.Fatalf("step %d at %v: Allowed = %v, Remaining = %d, RetryAfter = %v; want %v, %d, %v",
+						i, s.at, res.Allowed, res.Remaining, res.RetryAfter, s.allowed, s.remaining, s.retryAfter)
+				}
+				if res.Limit != defaults.MaxTokens {
+					t.Fatalf("step %d: Limit = %d, want %d", i, res.Limit, defaults.MaxTokens)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucketFractionalRefill(t *testing.T) {
+	// refill_rate 4: за 62.5ms начисляется четверть токена
+	cfg := Config{MaxTokens: 1, RefillRate: 4}
+	b := newAlgorithm(config.AlgorithmTokenBucket, cfg, testStart)
+	if !b.take(testStart).Allowed {
+		t.Fatal("full bucket rejected a request")
+	}
+
+	// Отклоненные запросы не теряют уже начисленные доли токена
+	const step = 62500 * time.Microsecond
+	for i, want := range []time.Duration{3 * step, 2 * step, step} {
+		res := b.take(testStart.Add(time.Duration(i+1) * step))
+		if res.Allowed || res.RetryAfter != want {
+			t.Fatalf("after %v: Allowed = %v, RetryAfter = %v; want rejected for %v", time.Duration(i+1)*step, res.Allowed, res.RetryAfter, want)
+		}
+	}
+	if res := b.take(testStart.Add(4 * step)); !res.Allowed {
+		t.Fatal("four quarter tokens did not add up to a whole token")
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimiter
+
+import "sync"
+
+// bucketShards - число независимых частей таблицы buckets; запросы клиентов из разных частей
+// не ждут друг друга
+const bucketShards = 64
+
+type bucketShard struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucketMap - таблица buckets по IP клиента, разбитая на части со своими блокировками
+type bucketMap struct {
+	shards [bucketShards]bucketShard
+}
+
+func newBucketMap() *bucketMap {
+	m := &bucketMap{}
+	for i := range m.shards {
+		m.shards[i].buckets = make(map[string]*bucket)
+	}
+	return m
+}
+
+// shard возвращает часть таблицы, в которой хранится bucket клиента (хеш FNV-1a по IP)
+func (m *bucketMap) shard(ip string) *bucketShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(ip); i++ {
+		h ^= uint32(ip[i])
+		h *= 16777619
+	}
+	return &m.shards[h%bucketShards]
+}
+
+func (m *bucketMap) delete(ip string) {
+	sh := m.shard(ip)
+	sh.mutex.Lock()
+	delete(sh.buckets, ip)
+	sh.mutex.Unlock()
+}
+
+func (m *bucketMap) len() int {
+	n := 0
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mutex.Lock()
+		n += len(sh.buckets)
+		sh.mutex.Unlock()
+	}
+	return n
+}
+
+// deleteFunc удаляет buckets, для которых del возвращает true, и возвращает их число.
+// Части таблицы блокируются по очереди, поэтому остальные клиенты обслуживаются без ожидания
+func (m *bucketMap) deleteFunc(del func(b *bucket) bool) int {
+	deleted := 0
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.mutex.Lock()
+		for ip, b := range sh.buckets {
+			if del(b) {
+				delete(sh.buckets, ip)
+				deleted++
+			}
+		}
+		sh.mutex.Unlock()
+	}
+	return deleted
+}
@@ -0,0 +1,71 @@
+package ratelimiter
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+const benchmarkClients = 100_000
+
+func newBenchmarkRateLimiter(b *testing.B) (*RateLimiter, []string) {
+	b.Helper()
+
+	cfg := &config.RateLimiter{
+		Enabled:          true,
+		CleanupInterval:  time.Hour,
+		BucketExpiration: time.Hour,
+		Algorithm:        config.AlgorithmTokenBucket,
+	}
+	cfg.Default.MaxTokens = 1000
+	cfg.Default.RefillRate = 1000
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(cancel)
+	rl := NewRateLimiter(ctx, stubRepository{}, nil, logging.NewZeroLoggerWithWriter(4, io.Discard), cfg)
+
+	// Buckets создаются заранее, чтобы измерять только обработку запросов
+	ips := make([]string, benchmarkClients)
+	for i := range ips {
+		ips[i] = "10." + strconv.Itoa(i>>16&0xff) + "." + strconv.Itoa(i>>8&0xff) + "." + strconv.Itoa(i&0xff)
+		rl.Allow(ctx, ips[i])
+	}
+	return rl, ips
+}
+
+// BenchmarkAllow сравнивает таблицу buckets, разбитую на части, с одной блокировкой на все buckets
+func BenchmarkAllow(b *testing.B) {
+	rl, ips := newBenchmarkRateLimiter(b)
+	ctx := context.Background()
+
+	b.Run("sharded", func(b *testing.B) {
+		var next atomic.Uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				rl.Allow(ctx, ips[next.Add(1)%benchmarkClients])
+			}
+		})
+	})
+
+	b.Run("single_lock", func(b *testing.B) {
+		var mux sync.Mutex
+		var next atomic.Uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				ip := ips[next.Add(1)%benchmarkClients]
+				mux.Lock()
+				rl.Allow(ctx, ip)
+				mux.Unlock()
+			}
+		})
+	})
+}
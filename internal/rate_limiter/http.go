@@ -1,24 +1,52 @@
 package ratelimiter
 
 import (
-	"net"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
+	"github.com/dielit66/cloud-camp-tt/internal/accesslog"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
+	"github.com/dielit66/cloud-camp-tt/internal/netutil"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
 	"github.com/dielit66/cloud-camp-tt/pkg/errors"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewRateLimiterHandler(rl *RateLimiter, logger logging.ILogger) func(http.Handler) http.Handler {
+// NewRateLimiterHandler пропускает запрос, если его разрешил rl, и добавляет к каждому ответу
+// заголовки RateLimit-* (и X-RateLimit-*, если legacyHeaders); отклоненные запросы получают 429 с Retry-After
+func NewRateLimiterHandler(rl Limiter, legacyHeaders bool, logger logging.ILogger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-			rl.logger.Info("Client IP extracted", map[string]interface{}{
+			ip := netutil.ClientIP(r)
+			logger.Info("Client IP extracted", map[string]interface{}{
 				"ip": ip,
 			})
-			ctx := r.Context()
 
-			if !rl.Allow(ctx, ip) {
+			ctx, span := tracing.Tracer().Start(r.Context(), "ratelimit.allow",
+				trace.WithAttributes(semconv.ClientAddress(ip)))
+			res := rl.Allow(ctx, ip)
+			span.SetAttributes(
+				attribute.Bool("ratelimit.allowed", res.Allowed),
+				attribute.Int("ratelimit.remaining", res.Remaining),
+			)
+			span.End()
+
+			decision := metrics.ResultAllowed
+			switch {
+			case !rl.IsEnabled():
+				decision = "disabled"
+			case !res.Allowed:
+				decision = metrics.ResultRejected
+			}
+			accesslog.FromContext(ctx).SetRateLimit(decision)
+
+			writeHeaders(w.Header(), res, legacyHeaders, rl.Now())
+
+			if !res.Allowed {
 				logger.Warn("Rate limit exceeded", map[string]interface{}{
 					"ip": ip,
 				})
@@ -37,15 +65,31 @@ func NewRateLimiterHandler(rl *RateLimiter, logger logging.ILogger) func(http.Ha
 	}
 }
 
-// getClientIP извлекает IP-адрес клиента
-func getClientIP(r *http.Request) string {
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
+// writeHeaders добавляет заголовки лимита по IETF draft (RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset в секундах) и при необходимости устаревшие X-RateLimit-* (X-RateLimit-Reset - Unix-время,
+// отсчитанное от now). Отклоненный запрос также получает Retry-After
+func writeHeaders(h http.Header, res Result, legacy bool, now time.Time) {
+	if !res.Allowed {
+		// Retry-After в целых секундах, округление вверх, чтобы повтор не пришел раньше пополнения
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
 	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	if res.Limit == 0 {
+		return
 	}
-	return ip
+
+	limit, remaining := strconv.Itoa(res.Limit), strconv.Itoa(res.Remaining)
+	h.Set("RateLimit-Limit", limit)
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+	if legacy {
+		h.Set("X-RateLimit-Limit", limit)
+		h.Set("X-RateLimit-Remaining", remaining)
+		// Момент сброса тоже округляется вверх до целой секунды
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(res.Reset).Add(time.Second-1).Unix(), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
@@ -0,0 +1,110 @@
+package ratelimiter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/clock/clocktest"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+func TestRateLimiterHandlerHeaders(t *testing.T) {
+	// fixed_window с max_tokens 3, refill_rate 2: окно 1.5s начинается с первого запроса,
+	// поэтому RateLimit-Reset и Retry-After округляются вверх до 2, а X-RateLimit-Reset - до testStart + 2s
+	defaults := Config{MaxTokens: 3, RefillRate: 2}
+	reset := strconv.FormatInt(testStart.Unix()+2, 10)
+
+	tests := []struct {
+		name     string
+		requests int
+		legacy   bool
+		code     int
+		want     map[string]string
+	}{
+		{
+			name:     "allowed",
+			requests: 1,
+			code:     http.StatusOK,
+			want: map[string]string{
+				"RateLimit-Limit":       "3",
+				"RateLimit-Remaining":   "2",
+				"RateLimit-Reset":       "2",
+				"Retry-After":           "",
+				"X-RateLimit-Limit":     "",
+				"X-RateLimit-Remaining": "",
+				"X-RateLimit-Reset":     "",
+			},
+		},
+		{
+			name:     "allowed legacy headers",
+			requests: 1,
+			legacy:   true,
+			code:     http.StatusOK,
+			want: map[string]string{
+				"RateLimit-Limit":       "3",
+				"RateLimit-Remaining":   "2",
+				"RateLimit-Reset":       "2",
+				"Retry-After":           "",
+				"X-RateLimit-Limit":     "3",
+				"X-RateLimit-Remaining": "2",
+				"X-RateLimit-Reset":     reset,
+			},
+		},
+		{
+			name:     "limited",
+			requests: 4,
+			code:     http.StatusTooManyRequests,
+			want: map[string]string{
+				"RateLimit-Limit":       "3",
+				"RateLimit-Remaining":   "0",
+				"RateLimit-Reset":       "2",
+				"Retry-After":           "2",
+				"X-RateLimit-Limit":     "",
+				"X-RateLimit-Remaining": "",
+				"X-RateLimit-Reset":     "",
+			},
+		},
+		{
+			name:     "limited legacy headers",
+			requests: 4,
+			legacy:   true,
+			code:     http.StatusTooManyRequests,
+			want: map[string]string{
+				"RateLimit-Limit":       "3",
+				"RateLimit-Remaining":   "0",
+				"RateLimit-Reset":       "2",
+				"Retry-After":           "2",
+				"X-RateLimit-Limit":     "3",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     reset,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newTestRateLimiter(t, config.AlgorithmFixedWindow, defaults, clocktest.NewFake())
+			logger := logging.NewZeroLoggerWithWriter(4, io.Discard)
+			handler := NewRateLimiterHandler(rl, tt.legacy, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			var w *httptest.ResponseRecorder
+			for range tt.requests {
+				w = httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d", w.Code, tt.code)
+			}
+			for name, want := range tt.want {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -4,12 +4,22 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dielit66/cloud-camp-tt/internal/clock"
 	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 )
 
+var (
+	// ErrConfigNotFound возвращается репозиторием, если для IP нет персональной конфигурации
+	ErrConfigNotFound = errors.New("config not found")
+	// ErrInvalidConfig возвращается репозиторием, если сохраненная конфигурация некорректна
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
 // Config represents the rate limit configuration
 type Config struct {
 	MaxTokens  int `json:"max_tokens"`
@@ -50,37 +60,84 @@ type ISettingsRepository interface {
 	DeleteConfig(ctx context.Context, ip string) error // Добавляем метод
 }
 
-type TokenBucket struct {
-	tokens     float64
-	config     Config
-	lastRefill time.Time
+// IBucketStore хранит состояние buckets вне процесса, общее для всех экземпляров балансировщика
+type IBucketStore interface {
+	// Take пополняет bucket клиента и списывает токен
+	Take(ctx context.Context, ip string, cfg Config) (Result, error)
+}
+
+// Limiter решает, пропустить ли запрос клиента. NewRateLimiterHandler не зависит от алгоритма
+type Limiter interface {
+	Allow(ctx context.Context, ip string) Result
+	IsEnabled() bool
+	// Now - текущее время по часам лимитера, от него считается X-RateLimit-Reset
+	Now() time.Time
+}
+
+// bucket - состояние лимита клиента по выбранному алгоритму (rate_limiter.algorithm)
+type bucket struct {
+	limit  algorithm
+	config Config
+	// lastSeen - время последнего запроса клиента, по нему удаляются неактивные buckets
+	lastSeen time.Time
+	// isDefault - bucket создан с настройками по умолчанию и сбрасывается при их изменении
+	isDefault bool
 }
 
 type RateLimiter struct {
-	buckets   map[string]*TokenBucket
+	buckets *bucketMap
+	// mutex защищает defaults; buckets блокируются по частям
 	mutex     sync.RWMutex
 	repo      ISettingsRepository
 	logger    logging.ILogger
-	ticker    *time.Ticker
 	cfg       *config.RateLimiter
+	defaults  Config
 	isEnabled bool
+	clock     clock.Clock
+
+	// store - общее хранилище buckets (nil - только локальные buckets)
+	store IBucketStore
+	// storeDownUntil - до какого момента (UnixNano) не обращаться к store после ошибки
+	storeDownUntil atomic.Int64
+}
+
+// Option - необязательная настройка rate limiter
+type Option func(*RateLimiter)
+
+// WithClock задает источник времени вместо системных часов, например фиксированное время в тестах
+func WithClock(c clock.Clock) Option {
+	return func(rl *RateLimiter) {
+		rl.clock = c
+	}
 }
 
-func NewRateLimiter(ctx context.Context, repo ISettingsRepository, logger logging.ILogger, cfg *config.RateLimiter) *RateLimiter {
+// NewRateLimiter создает rate limiter. Если store не nil, лимиты считаются по общим buckets,
+// а локальные используются как fallback при недоступности store
+func NewRateLimiter(ctx context.Context, repo ISettingsRepository, store IBucketStore, logger logging.ILogger, cfg *config.RateLimiter, opts ...Option) *RateLimiter {
 	rl := &RateLimiter{
-		buckets:   make(map[string]*TokenBucket),
+		buckets:   newBucketMap(),
 		repo:      repo,
+		store:     store,
 		logger:    logger,
 		cfg:       cfg,
 		isEnabled: cfg.Enabled,
+		clock:     clock.System{},
+		defaults: Config{
+			MaxTokens:  cfg.Default.MaxTokens,
+			RefillRate: cfg.Default.RefillRate,
+		},
+	}
+	for _, opt := range opts {
+		opt(rl)
 	}
 
 	if cfg.Enabled {
-		rl.ticker = time.NewTicker(cfg.RefillInterval)
-		go rl.StartRefillLoop(ctx)
-		rl.logger.Info("Rate limiter enabled, starting refill loop", map[string]interface{}{
-			"refill_interval":   cfg.RefillInterval.String(),
+		go rl.StartSweeper(ctx)
+		rl.logger.Info("Rate limiter enabled", map[string]interface{}{
+			"cleanup_interval":  cfg.CleanupInterval.String(),
 			"bucket_expiration": cfg.BucketExpiration.String(),
+			"algorithm":         cfg.Algorithm,
+			"distributed":       store != nil,
 		})
 	} else {
 		rl.logger.Info("Rate limiter disabled", nil)
@@ -93,121 +150,201 @@ func (rl *RateLimiter) IsEnabled() bool {
 	return rl.isEnabled
 }
 
-func (rl *RateLimiter) Allow(ctx context.Context, ip string) bool {
+func (rl *RateLimiter) Now() time.Time {
+	return rl.clock.Now()
+}
+
+// Allow решает, пропустить ли запрос клиента, и возвращает состояние его лимита
+func (rl *RateLimiter) Allow(ctx context.Context, ip string) Result {
 	if !rl.isEnabled {
 		rl.logger.Debug("Rate limiter disabled, allowing request", map[string]interface{}{
 			"ip": ip,
 		})
-		return true
+		return Result{Allowed: true}
 	}
 
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
+	if rl.store != nil {
+		if res, ok := rl.allowShared(ctx, ip); ok {
+			return res
+		}
+	}
+
+	now := rl.clock.Now()
+	sh, b := rl.lockBucket(ctx, ip, now)
+	res := b.limit.take(now)
+	res.Limit = b.config.MaxTokens
+	sh.mutex.Unlock()
+
+	rl.logger.Debug("Checked rate limit for IP", map[string]interface{}{
+		"ip":        ip,
+		"algorithm": rl.cfg.Algorithm,
+		"allowed":   res.Allowed,
+		"remaining": res.Remaining,
+	})
+	rl.observe(ip, res.Allowed)
+	return res
+}
 
-	bucket, exists := rl.buckets[ip]
+// lockBucket возвращает локальный bucket клиента вместе с заблокированной частью таблицы,
+// которую вызывающий должен разблокировать. Новый bucket создается с персональными настройками
+// из репозитория или настройками по умолчанию; репозиторий читается без блокировки
+func (rl *RateLimiter) lockBucket(ctx context.Context, ip string, now time.Time) (*bucketShard, *bucket) {
+	sh := rl.buckets.shard(ip)
+	sh.mutex.Lock()
+	b, exists := sh.buckets[ip]
 	if !exists {
-		cfg, err := rl.repo.GetConfig(ctx, ip)
-		if err != nil || cfg.MaxTokens == 0 {
-			rl.logger.Info("No config found for IP, using default", map[string]interface{}{
-				"ip":    ip,
-				"error": err,
-			})
-			cfg = Config{
-				MaxTokens:  rl.cfg.Default.MaxTokens,
-				RefillRate: rl.cfg.Default.RefillRate,
+		sh.mutex.Unlock()
+		cfg, isDefault := rl.clientConfig(ctx, ip)
+
+		sh.mutex.Lock()
+		// Пока читались настройки, bucket мог создать параллельный запрос того же клиента
+		if b, exists = sh.buckets[ip]; !exists {
+			b = &bucket{
+				limit:     newAlgorithm(rl.cfg.Algorithm, cfg, now),
+				config:    cfg,
+				isDefault: isDefault,
 			}
+			sh.buckets[ip] = b
+			rl.logger.Info("Created new bucket for IP", map[string]interface{}{
+				"ip":          ip,
+				"max_tokens":  cfg.MaxTokens,
+				"refill_rate": cfg.RefillRate,
+			})
 		}
-		bucket = &TokenBucket{
-			tokens:     float64(cfg.MaxTokens),
-			config:     cfg,
-			lastRefill: time.Now(),
-		}
-		rl.buckets[ip] = bucket
-		rl.logger.Info("Created new bucket for IP", map[string]interface{}{
-			"ip":          ip,
-			"max_tokens":  cfg.MaxTokens,
-			"refill_rate": cfg.RefillRate,
-		})
 	}
+	b.lastSeen = now
+	return sh, b
+}
 
-	rl.logger.Info("Checking rate limit for IP", map[string]interface{}{
-		"ip":            ip,
-		"tokens_before": bucket.tokens,
-		"last_refill":   bucket.lastRefill,
+// clientConfig возвращает персональные настройки клиента или настройки по умолчанию (isDefault)
+func (rl *RateLimiter) clientConfig(ctx context.Context, ip string) (cfg Config, isDefault bool) {
+	cfg, err := rl.repo.GetConfig(ctx, ip)
+	if err == nil && cfg.MaxTokens != 0 {
+		return cfg, false
+	}
+
+	rl.logger.Info("No config found for IP, using default", map[string]interface{}{
+		"ip":    ip,
+		"error": err,
 	})
+	return rl.Defaults(), true
+}
+
+// allowShared проверяет лимит по общему bucket в store. Локальный bucket при этом хранит настройки клиента,
+// чтобы не читать их из репозитория на каждый запрос. ok = false - store недоступен и нужно применить
+// fallback local; политики allow и deny применяются здесь же
+func (rl *RateLimiter) allowShared(ctx context.Context, ip string) (res Result, ok bool) {
+	d := rl.cfg.Distributed
 
-	if bucket.tokens >= 1 {
-		bucket.tokens -= 1
-		rl.logger.Info("Request allowed, tokens deducted", map[string]interface{}{
-			"ip":           ip,
-			"tokens_after": bucket.tokens,
+	now := rl.clock.Now()
+	if now.UnixNano() >= rl.storeDownUntil.Load() {
+		sh, b := rl.lockBucket(ctx, ip, now)
+		cfg := b.config
+		sh.mutex.Unlock()
+
+		takeCtx, cancel := context.WithTimeout(ctx, d.Timeout)
+		res, err := rl.store.Take(takeCtx, ip, cfg)
+		cancel()
+		if err == nil {
+			rl.observe(ip, res.Allowed)
+			return res, true
+		}
+
+		rl.storeDownUntil.Store(rl.clock.Now().Add(d.RetryInterval).UnixNano())
+		rl.logger.Warn("Shared rate limiter is unavailable, using fallback", map[string]interface{}{
+			"fallback":       d.Fallback,
+			"retry_interval": d.RetryInterval.String(),
+			"error":          err.Error(),
 		})
-		return true
+	}
+
+	// Лимит клиента не известен, поэтому в результате fallback заполнено только время повтора
+	switch d.Fallback {
+	case config.FallbackAllow:
+		rl.observe(ip, true)
+		return Result{Allowed: true}, true
+	case config.FallbackDeny:
+		rl.observe(ip, false)
+		return Result{RetryAfter: time.Duration(rl.storeDownUntil.Load() - now.UnixNano())}, true
+	default:
+		return Result{}, false
+	}
+}
+
+// observe записывает решение в лог и метрики
+func (rl *RateLimiter) observe(ip string, allowed bool) {
+	if allowed {
+		metrics.RateLimiterRequests.WithLabelValues(metrics.ResultAllowed).Inc()
+		return
 	}
 
 	rl.logger.Warn("Request rate limited", map[string]interface{}{
-		"ip":     ip,
-		"tokens": bucket.tokens,
+		"ip": ip,
 	})
-	return false
+	metrics.RateLimiterRequests.WithLabelValues(metrics.ResultRejected).Inc()
 }
 
-func (rl *RateLimiter) ClearBucket(ip string) {
+// IsDistributed сообщает, считаются ли лимиты по общим buckets в store
+func (rl *RateLimiter) IsDistributed() bool {
+	return rl.store != nil
+}
+
+// BucketsCount возвращает число активных buckets
+func (rl *RateLimiter) BucketsCount() int {
+	return rl.buckets.len()
+}
+
+// Defaults возвращает текущие настройки по умолчанию
+func (rl *RateLimiter) Defaults() Config {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+
+	return rl.defaults
+}
+
+// SetDefaults меняет настройки по умолчанию и сбрасывает buckets, созданные со старыми значениями
+func (rl *RateLimiter) SetDefaults(defaults Config) {
 	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
-	delete(rl.buckets, ip)
+	rl.defaults = defaults
+	rl.mutex.Unlock()
+
+	cleared := rl.buckets.deleteFunc(func(b *bucket) bool {
+		return b.isDefault
+	})
+
+	rl.logger.Info("Rate limiter defaults updated", map[string]interface{}{
+		"max_tokens":      defaults.MaxTokens,
+		"refill_rate":     defaults.RefillRate,
+		"cleared_buckets": cleared,
+	})
+}
+
+func (rl *RateLimiter) ClearBucket(ip string) {
+	rl.buckets.delete(ip)
 	rl.logger.Info("Bucket cleared", map[string]interface{}{
 		"ip": ip,
 	})
 }
 
-func (rl *RateLimiter) StartRefillLoop(ctx context.Context) {
-	rl.logger.Info("Starting rate limiter refill loop", map[string]interface{}{
-		"refill_interval":   rl.cfg.RefillInterval.String(),
-		"bucket_expiration": rl.cfg.BucketExpiration.String(),
-	})
+// StartSweeper раз в cleanup_interval удаляет buckets клиентов, от которых не было запросов
+// дольше bucket_expiration. Пополнение buckets считается при каждом запросе и от цикла не зависит
+func (rl *RateLimiter) StartSweeper(ctx context.Context) {
+	ticker := time.NewTicker(rl.cfg.CleanupInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			rl.logger.Info("Stopping rate limiter refill loop", nil)
-			rl.ticker.Stop()
+			rl.logger.Info("Stopping rate limiter sweeper", nil)
 			return
-		case <-rl.ticker.C:
-			rl.mutex.Lock()
-			rl.logger.Debug("Running bucket refill cycle", map[string]interface{}{
-				"buckets": len(rl.buckets),
+		case <-ticker.C:
+			now := rl.clock.Now()
+			removed := rl.buckets.deleteFunc(func(b *bucket) bool {
+				return now.Sub(b.lastSeen) > rl.cfg.BucketExpiration
 			})
-			for ip, bucket := range rl.buckets {
-				rl.refillBucket(bucket)
-				if time.Since(bucket.lastRefill) > rl.cfg.BucketExpiration {
-					rl.logger.Info("Removing expired bucket", map[string]interface{}{
-						"ip": ip,
-					})
-					delete(rl.buckets, ip)
-				}
-			}
-			rl.logger.Debug("Completed bucket refill cycle", map[string]interface{}{
-				"buckets": len(rl.buckets),
+			rl.logger.Debug("Removed expired buckets", map[string]interface{}{
+				"removed": removed,
 			})
-			rl.mutex.Unlock()
 		}
 	}
 }
-
-func (rl *RateLimiter) refillBucket(bucket *TokenBucket) {
-	now := time.Now()
-	elapsed := now.Sub(bucket.lastRefill).Seconds()
-	newTokens := bucket.tokens + elapsed*float64(bucket.config.RefillRate)
-	if newTokens > float64(bucket.config.MaxTokens) {
-		newTokens = float64(bucket.config.MaxTokens)
-	}
-	bucket.tokens = newTokens
-	bucket.lastRefill = now
-	rl.logger.Debug("Refilled bucket", map[string]interface{}{
-		"tokens":      bucket.tokens,
-		"max_tokens":  bucket.config.MaxTokens,
-		"refill_rate": bucket.config.RefillRate,
-		"elapsed_sec": elapsed,
-	})
-}
@@ -0,0 +1,333 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/internal/server"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// LevelSetter - логгер, уровень которого можно менять на лету
+type LevelSetter interface {
+	logging.ILogger
+	SetLevel(level int8)
+}
+
+// Reloader перечитывает конфиг по SIGHUP (и, опционально, при изменении файла),
+// сравнивает его с текущим и применяет только изменившиеся настройки.
+// Некорректный конфиг отклоняется целиком, старый продолжает работать.
+type Reloader struct {
+	path    string
+	current *config.Config
+	mux     sync.Mutex
+	modTime time.Time
+
+	lb     *server.LoadBalancer
+	rl     *ratelimiter.RateLimiter
+	logger LevelSetter
+}
+
+func NewReloader(path string, cfg *config.Config, lb *server.LoadBalancer, rl *ratelimiter.RateLimiter, l LevelSetter) *Reloader {
+	r := &Reloader{
+		path:    path,
+		current: cfg,
+		lb:      lb,
+		rl:      rl,
+		logger:  l,
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	return r
+}
+
+// Start ждет SIGHUP и, если включен reload.watch, периодически проверяет время изменения файла
+func (r *Reloader) Start(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	var watch <-chan time.Time
+	if r.current.Reload.Watch {
+		ticker := time.NewTicker(r.current.Reload.WatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+
+		r.logger.Info("Watching config file for changes", map[string]interface{}{
+			"path":     r.path,
+			"interval": r.current.Reload.WatchInterval.String(),
+		})
+	}
+
+	for {
+		select {
+		case <-sighup:
+			r.logger.Info("Got SIGHUP, reloading config", map[string]interface{}{
+				"path": r.path,
+			})
+			r.Reload(ctx)
+		case <-watch:
+			info, err := os.Stat(r.path)
+			if err != nil {
+				r.logger.Warn("Failed to stat config file", map[string]interface{}{
+					"path":  r.path,
+					"error": err.Error(),
+				})
+				continue
+			}
+			if info.ModTime().Equal(r.modTime) {
+				continue
+			}
+			r.logger.Info("Config file changed, reloading", map[string]interface{}{
+				"path": r.path,
+			})
+			r.Reload(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reload читает и валидирует конфиг, затем применяет отличия от текущего.
+// Проверки новых пулов работают, пока не отменен ctx
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	next, err := config.LoadConfig(r.path)
+	if err != nil {
+		return r.reject(err)
+	}
+
+	// lb_method (общий и маршрутов) проверяем до применения чего-либо, чтобы не оставить конфиг примененным наполовину
+	if err := validateBalancing(next); err != nil {
+		return r.reject(err)
+	}
+
+	prev := r.current
+	changed := make([]string, 0)
+
+	// Сначала собираем все без побочных эффектов: новые пулы создаются, но не запускаются,
+	// маршруты компилируются для итогового набора пулов. Ошибка здесь оставляет текущий конфиг нетронутым
+	upstreams := r.lb.Upstreams()
+	nextUpstreams := make(map[string]*server.Upstream, len(upstreams))
+	added := make([]*server.Upstream, 0)
+	for name, bp := range next.AllPools() {
+		up, exists := upstreams[name]
+		if !exists {
+			up = server.NewUpstream(name, bp, r.logger)
+			added = append(added, up)
+		}
+		nextUpstreams[name] = up
+	}
+
+	removed := make([]*server.Upstream, 0)
+	for name, up := range upstreams {
+		if _, exists := nextUpstreams[name]; !exists {
+			removed = append(removed, up)
+		}
+	}
+
+	var routes *server.RoutesUpdate
+	if len(added) > 0 || len(removed) > 0 || next.Server.LBMethod != prev.Server.LBMethod ||
+		next.Server.Hash != prev.Server.Hash || !reflect.DeepEqual(next.Routes, prev.Routes) {
+		if routes, err = r.lb.PrepareRoutes(&next.Server, next.Routes, nextUpstreams); err != nil {
+			return r.reject(err)
+		}
+	}
+
+	// Дальше конфиг только применяется, ошибок на этом шаге нет
+	if next.LoggerLevel != prev.LoggerLevel {
+		r.logger.SetLevel(next.LoggerLevel)
+		changed = append(changed, "logger_level")
+	}
+
+	// Новые пулы проверяются до того, как на них начнут ссылаться маршруты
+	for _, up := range added {
+		up.Start(ctx)
+		changed = append(changed, poolKey(up.Name))
+	}
+
+	prevPools := prev.AllPools()
+	for name, bp := range next.AllPools() {
+		if up, exists := upstreams[name]; exists {
+			changed = append(changed, r.reloadPool(up, prevPools[name], bp)...)
+		}
+	}
+
+	if routes != nil {
+		r.lb.ApplyRoutes(routes)
+		changed = append(changed, "routes")
+	}
+
+	// Удаленные пулы останавливаются, когда на них уже не ведет ни один маршрут
+	for _, up := range removed {
+		up.Stop()
+		changed = append(changed, poolKey(up.Name))
+	}
+
+	if !reflect.DeepEqual(next.Server.Retry, prev.Server.Retry) {
+		r.lb.SetRetry(next.Server.Retry)
+		changed = append(changed, "server.retry")
+	}
+
+	if !reflect.DeepEqual(next.Server.Timeouts, prev.Server.Timeouts) {
+		r.lb.SetTimeouts(next.Server.Timeouts)
+		changed = append(changed, "server.timeouts")
+	}
+
+	if next.RateLimiter.Default != prev.RateLimiter.Default {
+		r.rl.SetDefaults(ratelimiter.Config{
+			MaxTokens:  next.RateLimiter.Default.MaxTokens,
+			RefillRate: next.RateLimiter.Default.RefillRate,
+		})
+		changed = append(changed, "rate_limiter.default")
+	}
+
+	r.warnRestartRequired(prev, next)
+
+	r.current = next
+
+	r.logger.Info("Config reloaded", map[string]interface{}{
+		"path":    r.path,
+		"changed": changed,
+	})
+
+	return nil
+}
+
+// warnRestartRequired логирует настройки, которые нельзя применить без перезапуска
+func (r *Reloader) warnRestartRequired(prev, next *config.Config) {
+	restart := make([]string, 0)
+
+	if next.Server.Port != prev.Server.Port {
+		restart = append(restart, "server.port")
+	}
+	nextT, prevT := next.Server.Timeouts, prev.Server.Timeouts
+	if nextT.ReadHeader != prevT.ReadHeader || nextT.Read != prevT.Read || nextT.Write != prevT.Write || nextT.Idle != prevT.Idle {
+		restart = append(restart, "server.timeouts (read_header, read, write, idle)")
+	}
+	nextPools := next.AllPools()
+	for name, bp := range prev.AllPools() {
+		if nbp, exists := nextPools[name]; exists && nbp.Timeouts != bp.Timeouts {
+			restart = append(restart, poolKey(name)+".timeouts")
+		}
+	}
+	if next.RateLimiter.Enabled != prev.RateLimiter.Enabled {
+		restart = append(restart, "rate_limiter.enabled")
+	}
+	if next.RateLimiter.RateLimiterDb != prev.RateLimiter.RateLimiterDb {
+		restart = append(restart, "rate_limiter.db")
+	}
+	if next.RateLimiter.BucketExpiration != prev.RateLimiter.BucketExpiration ||
+		next.RateLimiter.CleanupInterval != prev.RateLimiter.CleanupInterval {
+		restart = append(restart, "rate_limiter intervals")
+	}
+	if next.RateLimiter.LegacyHeaders != prev.RateLimiter.LegacyHeaders {
+		restart = append(restart, "rate_limiter.legacy_headers")
+	}
+	if next.RateLimiter.Algorithm != prev.RateLimiter.Algorithm {
+		restart = append(restart, "rate_limiter.algorithm")
+	}
+	if next.RateLimiter.Distributed != prev.RateLimiter.Distributed {
+		restart = append(restart, "rate_limiter.distributed")
+	}
+	if next.AccessLog != prev.AccessLog {
+		restart = append(restart, "access_log")
+	}
+	if next.Tracing != prev.Tracing {
+		restart = append(restart, "tracing")
+	}
+	if !reflect.DeepEqual(next.Admin, prev.Admin) {
+		restart = append(restart, "admin")
+	}
+	if next.Reload != prev.Reload {
+		restart = append(restart, "reload")
+	}
+
+	if len(restart) > 0 {
+		r.logger.Warn("Some config changes require restart and were not applied", map[string]interface{}{
+			"settings": restart,
+		})
+	}
+}
+
+// reject логирует отклоненный конфиг и возвращает ошибку
+func (r *Reloader) reject(err error) error {
+	r.logger.Error("Config reload rejected, keeping current config", map[string]interface{}{
+		"path":  r.path,
+		"error": err.Error(),
+	})
+	return err
+}
+
+// reloadPool применяет изменения настроек существующего пула и возвращает список изменившихся настроек
+func (r *Reloader) reloadPool(up *server.Upstream, prev, next config.BackendPool) []string {
+	key := poolKey(up.Name)
+	changed := make([]string, 0)
+
+	if !reflect.DeepEqual(next.Entries(), prev.Entries()) {
+		// URL бэкендов уже проверены в LoadConfig, поэтому Sync здесь не возвращает ошибку
+		if err := up.Pool.Sync(next.Entries()); err != nil {
+			r.logger.Error("Failed to sync backend pool", map[string]interface{}{
+				"pool":  up.Name,
+				"error": err.Error(),
+			})
+		}
+		changed = append(changed, key)
+	}
+
+	if !reflect.DeepEqual(next.HealthCheck, prev.HealthCheck) {
+		up.HealthChecker.Update(next.HealthCheck)
+		changed = append(changed, key+".healthcheck")
+	}
+
+	if next.OutlierDetection != prev.OutlierDetection {
+		up.Outlier.Update(next.OutlierDetection)
+		changed = append(changed, key+".outlier_detection")
+	}
+
+	if next.CircuitBreaker != prev.CircuitBreaker {
+		up.Pool.SetCircuitBreaker(next.CircuitBreaker)
+		changed = append(changed, key+".circuit_breaker")
+	}
+
+	return changed
+}
+
+// validateBalancing проверяет, что алгоритмы балансировки сервера и всех маршрутов существуют
+func validateBalancing(cfg *config.Config) error {
+	if _, err := balancer.New(cfg.Server.LBMethod, &cfg.Server); err != nil {
+		return err
+	}
+	for _, rt := range cfg.Routes {
+		srv := rt.Balancing(&cfg.Server)
+		if _, err := balancer.New(srv.LBMethod, srv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolKey возвращает путь к пулу в конфиге для логов
+func poolKey(name string) string {
+	if name == config.DefaultPool {
+		return "pool"
+	}
+	return "pools." + name
+}
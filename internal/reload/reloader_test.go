@@ -0,0 +1,224 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/server"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+)
+
+const baseConfig = `
+logger_level: 4
+server:
+  lb_method: RR
+pool:
+  urls: [http://127.0.0.1:8081]
+  healthcheck:
+    timeout: 1s
+`
+
+// levelRecorder запоминает уровни, которые Reloader выставил логгеру
+type levelRecorder struct {
+	*logging.Logger
+	levels []int8
+}
+
+func (l *levelRecorder) SetLevel(level int8) {
+	l.levels = append(l.levels, level)
+}
+
+// newTestReloader записывает yaml в config.yaml и создает Reloader с балансировщиком по этому конфигу
+func newTestReloader(t *testing.T, yaml string) (*Reloader, *levelRecorder, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, yaml)
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	logger := &levelRecorder{Logger: logging.NewZeroLoggerWithWriter(4, io.Discard)}
+	upstreams := make(map[string]*server.Upstream)
+	for name, bp := range cfg.AllPools() {
+		upstreams[name] = server.NewUpstream(name, bp, logger)
+	}
+	lb := server.NewLoadBalancer(upstreams, logger, nil, nil)
+	if err := lb.SetRoutes(&cfg.Server, cfg.Routes); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	lb.SetRetry(cfg.Server.Retry)
+	lb.SetTimeouts(cfg.Server.Timeouts)
+	return NewReloader(path, cfg, lb, nil, logger), logger, path
+}
+
+// serve проксирует GET path через lb так же, как основной обработчик сервера
+func serve(lb *server.LoadBalancer, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	middleware.WithRequestID(http.HandlerFunc(lb.ServeProxy)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func writeConfig(t *testing.T, path, yaml string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadRejectsUnknownLBMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "server",
+			yaml: `
+logger_level: 1
+server:
+  lb_method: XYZ
+pool:
+  urls: [http://127.0.0.1:8081]
+  healthcheck:
+    timeout: 1s
+pools:
+  extra:
+    urls: [http://127.0.0.1:8091]
+    healthcheck:
+      timeout: 1s
+`,
+		},
+		{
+			name: "route",
+			yaml: `
+logger_level: 1
+server:
+  lb_method: RR
+pool:
+  urls: [http://127.0.0.1:8081]
+  healthcheck:
+    timeout: 1s
+pools:
+  extra:
+    urls: [http://127.0.0.1:8091]
+    healthcheck:
+      timeout: 1s
+routes:
+- path_prefix: /extra
+  pool: extra
+  lb_method: XYZ
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, logger, path := newTestReloader(t, baseConfig)
+			prev := r.current
+
+			writeConfig(t, path, tt.yaml)
+			if err := r.Reload(context.Background()); err == nil {
+				t.Fatal("Reload accepted unknown lb_method")
+			}
+
+			// Конфиг отклонен целиком: ни уровень логов, ни новый пул не применены
+			if r.current != prev {
+				t.Fatal("current config replaced by rejected one")
+			}
+			if len(logger.levels) != 0 {
+				t.Fatalf("logger level changed to %v by rejected config", logger.levels)
+			}
+			if _, exists := r.lb.Upstream("extra"); exists {
+				t.Fatal("pool from rejected config was added")
+			}
+		})
+	}
+}
+
+func TestReloadAppliesChangesWithoutDroppingRequests(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defaultSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+	}))
+	defer defaultSrv.Close()
+	// При падении теста держащий соединение запрос отпускается до закрытия сервера
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+	var extraHits atomic.Int32
+	extraSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/extra/a" {
+			extraHits.Add(1)
+		}
+	}))
+	defer extraSrv.Close()
+
+	pool := fmt.Sprintf(`
+pool:
+  urls: [%s]
+  healthcheck:
+    timeout: 1s
+`, defaultSrv.URL)
+	r, logger, path := newTestReloader(t, "logger_level: 4\nserver:\n  lb_method: RR\n"+pool)
+	prevDefault, _ := r.lb.Upstream(config.DefaultPool)
+
+	slow := make(chan int, 1)
+	go func() {
+		slow <- serve(r.lb, "/slow").Code
+	}()
+	<-entered
+
+	// Уровень логов, lb_method, новый пул и маршрут на него меняются одним reload
+	writeConfig(t, path, fmt.Sprintf(`
+logger_level: 1
+server:
+  lb_method: LC
+%s
+pools:
+  extra:
+    urls: [%s]
+    healthcheck:
+      timeout: 1s
+routes:
+- path_prefix: /extra
+  pool: extra
+`, pool, extraSrv.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if len(logger.levels) != 1 || logger.levels[0] != 1 {
+		t.Fatalf("logger levels = %v, want [1]", logger.levels)
+	}
+	// Неизмененный пул не пересоздается, поэтому состояние его бэкендов сохраняется
+	if up, _ := r.lb.Upstream(config.DefaultPool); up != prevDefault {
+		t.Fatal("unchanged default pool was recreated")
+	}
+	w := serve(r.lb, "/extra/a")
+	if w.Code != http.StatusOK || extraHits.Load() != 1 {
+		t.Fatalf("/extra after reload: status %d, extra pool hits %d; want 200 from the new pool", w.Code, extraHits.Load())
+	}
+
+	// Запрос, начатый до reload, дорабатывается
+	releaseOnce.Do(func() { close(release) })
+	if code := <-slow; code != http.StatusOK {
+		t.Fatalf("in-flight request status = %d, want %d", code, http.StatusOK)
+	}
+}
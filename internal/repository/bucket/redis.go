@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 
 	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
 	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RedisBucketSettingsRepository struct {
@@ -28,23 +32,32 @@ func NewRedisBucketSettingsRepository(l logging.ILogger, cfg *config.RateLimiter
 	}
 }
 
+// startSpan создает client span для операции с Redis
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "redis "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(op)))
+}
+
 func (r *RedisBucketSettingsRepository) GetConfig(ctx context.Context, ip string) (ratelimiter.Config, error) {
+	ctx, span := startSpan(ctx, "GET")
+	defer span.End()
+
 	key := "rate_limit:config:" + ip
 	val, err := r.db.Get(ctx, key).Result()
 	if err == redis.Nil {
-		r.logger.Info("No config found, using default", map[string]interface{}{
+		r.logger.Info("No config found", map[string]interface{}{
 			"ip": ip,
 		})
-		return ratelimiter.Config{
-			MaxTokens:  r.cfg.Default.MaxTokens,
-			RefillRate: r.cfg.Default.RefillRate,
-		}, nil
+		return ratelimiter.Config{}, ratelimiter.ErrConfigNotFound
 	}
 	if err != nil {
 		r.logger.Error("Failed to get config", map[string]interface{}{
 			"ip":    ip,
 			"error": err.Error(),
 		})
+		metrics.RedisErrors.WithLabelValues("get").Inc()
+		tracing.RecordError(span, err)
 		return ratelimiter.Config{}, err
 	}
 
@@ -58,21 +71,21 @@ func (r *RedisBucketSettingsRepository) GetConfig(ctx context.Context, ip string
 	}
 
 	if config.MaxTokens <= 0 || config.RefillRate <= 0 {
-		r.logger.Warn("Invalid config, using default", map[string]interface{}{
+		r.logger.Warn("Invalid config", map[string]interface{}{
 			"ip":          ip,
 			"max_tokens":  config.MaxTokens,
 			"refill_rate": config.RefillRate,
 		})
-		return ratelimiter.Config{
-			MaxTokens:  r.cfg.Default.MaxTokens,
-			RefillRate: r.cfg.Default.RefillRate,
-		}, nil
+		return ratelimiter.Config{}, ratelimiter.ErrInvalidConfig
 	}
 
 	return config, nil
 }
 
 func (r *RedisBucketSettingsRepository) SetConfig(ctx context.Context, ip string, config ratelimiter.Config) error {
+	ctx, span := startSpan(ctx, "SET")
+	defer span.End()
+
 	key := "rate_limit:config:" + ip
 	data, err := json.Marshal(config)
 	if err != nil {
@@ -88,6 +101,8 @@ func (r *RedisBucketSettingsRepository) SetConfig(ctx context.Context, ip string
 			"ip":    ip,
 			"error": err.Error(),
 		})
+		metrics.RedisErrors.WithLabelValues("set").Inc()
+		tracing.RecordError(span, err)
 		return err
 	}
 
@@ -100,12 +115,17 @@ func (r *RedisBucketSettingsRepository) SetConfig(ctx context.Context, ip string
 }
 
 func (r *RedisBucketSettingsRepository) DeleteConfig(ctx context.Context, ip string) error {
+	ctx, span := startSpan(ctx, "DEL")
+	defer span.End()
+
 	key := "rate_limit:config:" + ip
 	if err := r.db.Del(ctx, key).Err(); err != nil {
 		r.logger.Error("Failed to delete config", map[string]interface{}{
 			"ip":    ip,
 			"error": err.Error(),
 		})
+		metrics.RedisErrors.WithLabelValues("delete").Inc()
+		tracing.RecordError(span, err)
 		return err
 	}
 	r.logger.Info("Config deleted from Redis", map[string]interface{}{
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript атомарно пополняет bucket по прошедшему времени и списывает токен.
+// Время берется из Redis, чтобы расхождение часов экземпляров балансировщика не влияло на лимит.
+// KEYS[1] - ключ bucket, ARGV[1] - max_tokens, ARGV[2] - refill_rate (токенов в секунду),
+// ARGV[3] - TTL ключа в миллисекундах. Возвращает {1|0, оставшиеся токены}
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+
+return {allowed, tostring(tokens)}
+`)
+
+// RedisBucketStore хранит состояние token bucket'ов в Redis, общее для всех экземпляров балансировщика
+type RedisBucketStore struct {
+	db     *redis.Client
+	logger logging.ILogger
+}
+
+// BucketStore возвращает хранилище buckets, работающее через то же подключение к Redis
+func (r *RedisBucketSettingsRepository) BucketStore() *RedisBucketStore {
+	return &RedisBucketStore{
+		db:     r.db,
+		logger: r.logger,
+	}
+}
+
+func (s *RedisBucketStore) Take(ctx context.Context, ip string, cfg ratelimiter.Config) (ratelimiter.Result, error) {
+	ctx, span := startSpan(ctx, "EVALSHA")
+	defer span.End()
+
+	// Полный bucket не отличается от отсутствующего, поэтому ключ живет, пока bucket не наполнится
+	ttl := time.Duration(math.Ceil(float64(cfg.MaxTokens)/float64(cfg.RefillRate)*1000)) * time.Millisecond
+
+	key := "rate_limit:bucket:" + ip
+	res, err := takeScript.Run(ctx, s.db, []string{key}, cfg.MaxTokens, cfg.RefillRate, ttl.Milliseconds()+1).Slice()
+	if err != nil {
+		s.logger.Error("Failed to take token from shared bucket", map[string]interface{}{
+			"ip":    ip,
+			"error": err.Error(),
+		})
+		metrics.RedisErrors.WithLabelValues("take").Inc()
+		tracing.RecordError(span, err)
+		return ratelimiter.Result{}, err
+	}
+
+	allowed, _ := res[0].(int64)
+	raw, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return ratelimiter.Result{}, err
+	}
+
+	s.logger.Debug("Token taken from shared bucket", map[string]interface{}{
+		"ip":      ip,
+		"allowed": allowed == 1,
+		"tokens":  tokens,
+	})
+	return ratelimiter.TokenBucketResult(allowed == 1, tokens, cfg), nil
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dielit66/cloud-camp-tt/internal/clock/clocktest"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+var testBucketConfig = ratelimiter.Config{MaxTokens: 10, RefillRate: 1}
+
+// newTestRepository создает репозиторий с отдельным подключением к mr, как у отдельного экземпляра балансировщика
+func newTestRepository(t *testing.T, mr *miniredis.Miniredis) *RedisBucketSettingsRepository {
+	t.Helper()
+
+	cfg := &config.RateLimiter{RateLimiterDb: config.RateLimiterDb{Host: mr.Host(), Port: mr.Port()}}
+	repo := NewRedisBucketSettingsRepository(logging.NewZeroLoggerWithWriter(4, io.Discard), cfg)
+	t.Cleanup(func() { repo.db.Close() })
+	return repo
+}
+
+// newFrozenRedis запускает miniredis, в котором TIME возвращает заданное время
+func newFrozenRedis(t *testing.T) (*miniredis.Miniredis, time.Time) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	mr.SetTime(clocktest.Start)
+	return mr, clocktest.Start
+}
+
+func take(t *testing.T, s *RedisBucketStore, ip string) ratelimiter.Result {
+	t.Helper()
+
+	res, err := s.Take(context.Background(), ip, testBucketConfig)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	return res
+}
+
+func TestRedisBucketStoreSharedBetweenInstances(t *testing.T) {
+	mr, _ := newFrozenRedis(t)
+	stores := []*RedisBucketStore{
+		newTestRepository(t, mr).BucketStore(),
+		newTestRepository(t, mr).BucketStore(),
+	}
+
+	// Время в Redis стоит, поэтому общий bucket отдает ровно max_tokens токенов на оба экземпляра
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(s *RedisBucketStore) {
+			defer wg.Done()
+			<-start
+			res, err := s.Take(context.Background(), "10.0.0.1", testBucketConfig)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if res.Allowed {
+				allowed.Add(1)
+			}
+		}(stores[i%len(stores)])
+	}
+	close(start)
+	wg.Wait()
+
+	if got := int(allowed.Load()); got != testBucketConfig.MaxTokens {
+		t.Fatalf("allowed %d requests, want %d", got, testBucketConfig.MaxTokens)
+	}
+}
+
+func TestRedisBucketStoreRefill(t *testing.T) {
+	mr, now := newFrozenRedis(t)
+	s := newTestRepository(t, mr).BucketStore()
+
+	for i := 0; i < testBucketConfig.MaxTokens; i++ {
+		take(t, s, "10.0.0.1")
+	}
+	res := take(t, s, "10.0.0.1")
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("empty bucket: Allowed = %v, RetryAfter = %v, want rejected for 1s", res.Allowed, res.RetryAfter)
+	}
+
+	// Через 1.5s при refill_rate 1 пополнен один токен и половина следующего
+	mr.SetTime(now.Add(1500 * time.Millisecond))
+	res = take(t, s, "10.0.0.1")
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after refill: Allowed = %v, Remaining = %d, want allowed with 0 left", res.Allowed, res.Remaining)
+	}
+	res = take(t, s, "10.0.0.1")
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("half token: Allowed = %v, RetryAfter = %v, want rejected for 500ms", res.Allowed, res.RetryAfter)
+	}
+}
+
+func TestRedisBucketStoreKeyExpires(t *testing.T) {
+	mr, _ := newFrozenRedis(t)
+	s := newTestRepository(t, mr).BucketStore()
+	const key = "rate_limit:bucket:10.0.0.1"
+
+	for i := 0; i < testBucketConfig.MaxTokens; i++ {
+		take(t, s, "10.0.0.1")
+	}
+
+	// TTL - время полного пополнения bucket (10 токенов при 1 в секунду) плюс 1ms
+	if ttl := mr.TTL(key); ttl != 10*time.Second+time.Millisecond {
+		t.Fatalf("TTL = %v, want 10.001s", ttl)
+	}
+
+	// Время в Redis не двигается, поэтому полный bucket после истечения ключа - заслуга TTL, а не пополнения
+	mr.FastForward(10*time.Second + time.Millisecond)
+	if mr.Exists(key) {
+		t.Fatal("bucket key did not expire")
+	}
+	res := take(t, s, "10.0.0.1")
+	if !res.Allowed || res.Remaining != testBucketConfig.MaxTokens-1 {
+		t.Fatalf("after expiry: Allowed = %v, Remaining = %d, want a full bucket", res.Allowed, res.Remaining)
+	}
+}
+
+// countingStore считает обращения к store, чтобы проверить паузу retry_interval
+type countingStore struct {
+	ratelimiter.IBucketStore
+	calls atomic.Int32
+}
+
+func (s *countingStore) Take(ctx context.Context, ip string, cfg ratelimiter.Config) (ratelimiter.Result, error) {
+	s.calls.Add(1)
+	return s.IBucketStore.Take(ctx, ip, cfg)
+}
+
+func TestRateLimiterFallback(t *testing.T) {
+	const retryInterval = 5 * time.Second
+
+	tests := []struct {
+		fallback string
+		// allowed - сколько из 15 запросов пропускается, пока Redis недоступен
+		allowed int
+	}{
+		{fallback: config.FallbackLocal, allowed: 3},
+		{fallback: config.FallbackAllow, allowed: 15},
+		{fallback: config.FallbackDeny, allowed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fallback, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			repo := newTestRepository(t, mr)
+			store := &countingStore{IBucketStore: repo.BucketStore()}
+
+			cfg := &config.RateLimiter{
+				Enabled:          true,
+				CleanupInterval:  time.Minute,
+				BucketExpiration: time.Hour,
+				Algorithm:        config.AlgorithmTokenBucket,
+				Distributed: config.Distributed{
+					Enabled:       true,
+					Timeout:       time.Second,
+					Fallback:      tt.fallback,
+					RetryInterval: retryInterval,
+				},
+			}
+			cfg.Default.MaxTokens = 3
+			cfg.Default.RefillRate = 1
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := clocktest.NewFake()
+			rl := ratelimiter.NewRateLimiter(ctx, repo, store, logging.NewZeroLoggerWithWriter(4, io.Discard), cfg, ratelimiter.WithClock(clock))
+
+			if !rl.Allow(ctx, "10.0.0.1").Allowed {
+				t.Fatal("request rejected while Redis is up")
+			}
+
+			mr.Close()
+			before := store.calls.Load()
+			allowed := 0
+			for i := 0; i < 15; i++ {
+				clock.Advance(10 * time.Millisecond)
+				res := rl.Allow(ctx, "10.0.0.1")
+				if res.Allowed {
+					allowed++
+				} else if res.RetryAfter <= 0 {
+					t.Fatalf("rejected request has RetryAfter %v", res.RetryAfter)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Fatalf("allowed %d of 15 requests with Redis down, want %d", allowed, tt.allowed)
+			}
+			// После первой ошибки store не вызывается до конца retry_interval
+			if calls := store.calls.Load() - before; calls != 1 {
+				t.Fatalf("store called %d times during retry_interval, want 1", calls)
+			}
+
+			// После retry_interval снова используется общий bucket
+			if err := mr.Restart(); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(retryInterval)
+			before = store.calls.Load()
+			res := rl.Allow(ctx, "10.0.0.1")
+			if store.calls.Load() != before+1 || !res.Allowed || res.Limit != cfg.Default.MaxTokens {
+				t.Fatalf("after retry_interval: store calls %d, result %+v, want the shared bucket", store.calls.Load()-before, res)
+			}
+		})
+	}
+}
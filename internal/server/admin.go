@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dielit66/cloud-camp-tt/internal/admin"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	errors_middleware "github.com/dielit66/cloud-camp-tt/pkg/errors/middleware"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+)
+
+// registerAdmin регистрирует admin API на mux; каждый обработчик оборачивается в wrap
+func (lb *LoadBalancer) registerAdmin(mux *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, wrap(h))
+	}
+
+	handle("/api/ratelimit/config", lb.handleRateLimitConfig)
+	handle("/api/ratelimit/config/", lb.handleRateLimitConfig)
+	// - /api/backends для управления бэкендами пула без перезапуска
+	handle("/api/backends", lb.handleBackends)
+	handle("/api/backends/enable", lb.handleBackendState(true))
+	handle("/api/backends/disable", lb.handleBackendState(false))
+	// - /api/splits для просмотра статистики и изменения веса canary-сплитов
+	handle("/api/splits", lb.handleSplits)
+	// - /api/status для просмотра состояния пулов, маршрутов и rate limiter
+	handle("/api/status", lb.handleStatus)
+}
+
+// adminGuard возвращает middleware admin API: аутентификация, проверка роли и audit log
+func adminGuard(auth *admin.Authenticator, audit *admin.Auditor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return audit.Middleware(auth.Middleware(next))
+	}
+}
+
+// startAdmin запускает отдельный listener admin API. Ошибка открытия listener'а - ошибка запуска
+func (lb *LoadBalancer) startAdmin(cfg config.Admin, guard func(http.Handler) http.Handler, timeouts config.ServerTimeouts) (*http.Server, error) {
+	ln, err := admin.Listen(cfg)
+	if err != nil {
+		lb.logger.Error("failed to open admin listener", map[string]interface{}{
+			"listen": cfg.Listen,
+			"error":  err.Error(),
+		})
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	lb.registerAdmin(mux, guard)
+
+	srv := &http.Server{
+		Handler:           middleware.WithRequestID(errors_middleware.ErrorHandler(mux)),
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+
+	go func() {
+		lb.logger.Info("starting admin server", map[string]interface{}{
+			"listen": cfg.Listen,
+			"tls":    cfg.TLS.CertFile != "",
+		})
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			lb.logger.Fatal("admin server returned error", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	return srv, nil
+}
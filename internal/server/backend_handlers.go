@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/errors"
+)
+
+// handleBackends обслуживает /api/backends:
+// GET - список, POST - добавление, DELETE ?url= - удаление с drain.
+// Пул выбирается параметром ?pool= (по умолчанию - пул из блока pool)
+func (lb *LoadBalancer) handleBackends(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		lb.handleListBackends(w, r)
+	case http.MethodPost:
+		lb.handleAddBackend(w, r)
+	case http.MethodDelete:
+		lb.handleRemoveBackend(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackendState обслуживает POST /api/backends/enable?url= и /api/backends/disable?url=
+func (lb *LoadBalancer) handleBackendState(isEnabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rawURL := r.URL.Query().Get("url")
+		if rawURL == "" {
+			writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "url is required"))
+			return
+		}
+
+		pool, ok := lb.requestPool(w, r)
+		if !ok {
+			return
+		}
+
+		b, err := pool.SetEnabled(rawURL, isEnabled)
+		if err != nil {
+			writeAPIError(w, backendAPIError(err))
+			return
+		}
+
+		lb.writeJSON(w, http.StatusOK, backend.NewBackendResponse(b))
+	}
+}
+
+func (lb *LoadBalancer) handleListBackends(w http.ResponseWriter, r *http.Request) {
+	pool, ok := lb.requestPool(w, r)
+	if !ok {
+		return
+	}
+
+	backends := pool.List()
+
+	resp := make([]backend.BackendResponse, 0, len(backends))
+	for _, b := range backends {
+		resp = append(resp, backend.NewBackendResponse(b))
+	}
+
+	lb.writeJSON(w, http.StatusOK, resp)
+}
+
+func (lb *LoadBalancer) handleAddBackend(w http.ResponseWriter, r *http.Request) {
+	var req backend.AddBackendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		lb.logger.Warn("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		})
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		lb.logger.Warn("Invalid add backend request", map[string]interface{}{
+			"url":   req.URL,
+			"error": err.Error(),
+		})
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	pool, ok := lb.requestPool(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := pool.Add(config.Backend{URL: req.URL, Weight: req.Weight})
+	if err != nil {
+		lb.logger.Warn("Failed to add backend", map[string]interface{}{
+			"url":   req.URL,
+			"error": err.Error(),
+		})
+		writeAPIError(w, backendAPIError(err))
+		return
+	}
+
+	lb.writeJSON(w, http.StatusCreated, backend.NewBackendResponse(b))
+}
+
+func (lb *LoadBalancer) handleRemoveBackend(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "url is required"))
+		return
+	}
+
+	pool, ok := lb.requestPool(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := pool.Remove(rawURL); err != nil {
+		lb.logger.Warn("Failed to remove backend", map[string]interface{}{
+			"url":   rawURL,
+			"error": err.Error(),
+		})
+		writeAPIError(w, backendAPIError(err))
+		return
+	}
+
+	// Бэкенд уже не получает новые запросы, текущие дорабатываются в фоне
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requestPool возвращает пул из параметра ?pool= или пишет 404, если такого пула нет
+func (lb *LoadBalancer) requestPool(w http.ResponseWriter, r *http.Request) (*backend.Pool, bool) {
+	name := r.URL.Query().Get("pool")
+	if name == "" {
+		name = config.DefaultPool
+	}
+
+	up, ok := lb.Upstream(name)
+	if !ok {
+		writeAPIError(w, errors.NewAPIError(http.StatusNotFound, "pool not found"))
+		return nil, false
+	}
+	return up.Pool, true
+}
+
+func backendAPIError(err error) *errors.APIError {
+	switch {
+	case stderrors.Is(err, backend.ErrBackendNotFound):
+		return errors.NewAPIError(http.StatusNotFound, err.Error())
+	case stderrors.Is(err, backend.ErrBackendExists):
+		return errors.NewAPIError(http.StatusConflict, err.Error())
+	case stderrors.Is(err, backend.ErrInvalidURL):
+		return errors.NewAPIError(http.StatusBadRequest, err.Error())
+	default:
+		return errors.NewAPIError(http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, err *errors.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	w.Write(err.ToJSON())
+}
+
+func (lb *LoadBalancer) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		lb.logger.Error("Failed to encode response", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
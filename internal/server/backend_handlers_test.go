@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRemoveBackendDrainsInFlightRequests(t *testing.T) {
+	entered := make(chan string, 1)
+	release := make(chan struct{})
+	hits := make(map[string]*atomic.Int32)
+	urls := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		var srv *httptest.Server
+		n := &atomic.Int32{}
+		srv = countingBackend(t, n, func(w http.ResponseWriter, r *http.Request) {
+			// /slow держит соединение, пока тест не отпустит его
+			if r.URL.Path == "/slow" {
+				entered <- srv.URL
+				<-release
+			}
+		})
+		hits[srv.URL] = n
+		urls = append(urls, srv.URL)
+	}
+
+	lb := newTestLoadBalancer(t, config.Server{LBMethod: "RR"}, config.BackendPool{URLs: urls})
+	up, _ := lb.Upstream(config.DefaultPool)
+
+	slow := make(chan int, 1)
+	go func() {
+		slow <- serve(lb, httptest.NewRequest(http.MethodGet, "/slow", nil)).Code
+	}()
+	removed := <-entered
+	b, err := up.Pool.Get(removed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	lb.handleBackends(w, httptest.NewRequest(http.MethodDelete, "/api/backends?url="+url.QueryEscape(removed), nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	// Новые запросы идут только на оставшийся бэкенд
+	before := hits[removed].Load()
+	for i := 0; i < 5; i++ {
+		if code := serve(lb, httptest.NewRequest(http.MethodGet, "/", nil)).Code; code != http.StatusOK {
+			t.Fatalf("request after removal: status %d", code)
+		}
+	}
+	if got := hits[removed].Load() - before; got != 0 {
+		t.Fatalf("removed backend got %d new requests", got)
+	}
+
+	// Запрос, начатый до удаления, дорабатывается
+	select {
+	case code := <-slow:
+		t.Fatalf("in-flight request finished with %d before release", code)
+	default:
+	}
+	close(release)
+	if code := <-slow; code != http.StatusOK {
+		t.Fatalf("in-flight request status = %d, want %d", code, http.StatusOK)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&b.ActiveConnections) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("removed backend still has active connections")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// backendSeries считает серии метрик с меткой backend=url в общем реестре
+func backendSeries(t *testing.T, url string) int {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "backend" && l.GetValue() == url {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// waitSeries ждет, пока у бэкенда останется want серий (серии удаляются в фоне после drain)
+func waitSeries(t *testing.T, url string, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for backendSeries(t, url) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("backend %s has %d metric series, want %d", url, backendSeries(t, url), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRemovedBackendMetricsDeleted(t *testing.T) {
+	var hits atomic.Int32
+	urls := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		urls = append(urls, countingBackend(t, &hits, func(w http.ResponseWriter, r *http.Request) {}).URL)
+	}
+	lb := newTestLoadBalancer(t, config.Server{LBMethod: "RR"}, config.BackendPool{URLs: urls})
+	up, _ := lb.Upstream(config.DefaultPool)
+
+	// По одному запросу и одной проверке на бэкенд: серии requests, request_duration и healthcheck_duration
+	for range urls {
+		serve(lb, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	up.HealthChecker.RunCycle(context.Background(), up.Pool)
+	series := backendSeries(t, urls[0])
+	if series == 0 || backendSeries(t, urls[1]) != series {
+		t.Fatalf("metric series before removal: %d and %d", series, backendSeries(t, urls[1]))
+	}
+
+	// Удаление через admin API
+	w := httptest.NewRecorder()
+	lb.handleBackends(w, httptest.NewRequest(http.MethodDelete, "/api/backends?url="+url.QueryEscape(urls[0]), nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE status = %d", w.Code)
+	}
+	waitSeries(t, urls[0], 0)
+
+	// Бэкенд, пересозданный при reload из-за веса, остается в пуле вместе со своими сериями
+	if err := up.Pool.Sync([]config.Backend{{URL: urls[1], Weight: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	// Без активных запросов drain завершается сразу, серии удалялись бы в фоне за это время
+	time.Sleep(100 * time.Millisecond)
+	if got := backendSeries(t, urls[1]); got != series {
+		t.Fatalf("recreated backend has %d metric series, want %d", got, series)
+	}
+
+	// Бэкенд, удаленный из конфига при reload
+	if err := up.Pool.Sync([]config.Backend{{URL: "http://127.0.0.1:8099"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitSeries(t, urls[1], 0)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"strings"
 
@@ -128,6 +129,10 @@ func (lb *LoadBalancer) handleGetConfig(w http.ResponseWriter, r *http.Request)
 	}
 
 	config, err := lb.repo.GetConfig(r.Context(), ip)
+	if stderrors.Is(err, ratelimiter.ErrConfigNotFound) || stderrors.Is(err, ratelimiter.ErrInvalidConfig) {
+		// Персональной конфигурации нет - клиент работает с настройками по умолчанию
+		config, err = lb.rl.Defaults(), nil
+	}
 	if err != nil {
 		lb.logger.Error("Failed to get config", map[string]interface{}{
 			"ip":    ip,
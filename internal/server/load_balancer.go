@@ -2,25 +2,118 @@ package server
 
 import (
 	"net/http"
+	"sync/atomic"
 
-	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
 	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
 	"github.com/dielit66/cloud-camp-tt/pkg/logging"
 )
 
 type LoadBalancer struct {
 	server *http.Server
-	pool   *backend.Pool
 	logger logging.ILogger
 	rl     *ratelimiter.RateLimiter
 	repo   ratelimiter.ISettingsRepository
+
+	// upstreams хранит именованные пулы бэкендов; заменяется целиком при hot reload
+	upstreams atomic.Pointer[map[string]*Upstream]
+	// routes хранит маршруты с их пулами и алгоритмами балансировки; заменяется атомарно при hot reload
+	routes atomic.Pointer[routeTable]
+	// retry хранит текущие настройки повторов и бюджет; заменяется при hot reload
+	retry atomic.Pointer[retryPolicy]
+	// timeouts хранит дедлайны запросов (общий и по префиксам путей)
+	timeouts atomic.Pointer[config.ServerTimeouts]
+}
+
+type lbMethod struct {
+	name     string
+	balancer balancer.Balancer
 }
 
-func NewLoadBalancer(pool *backend.Pool, l logging.ILogger, rl *ratelimiter.RateLimiter, repo ratelimiter.ISettingsRepository) *LoadBalancer {
-	return &LoadBalancer{
-		pool:   pool,
+func NewLoadBalancer(upstreams map[string]*Upstream, l logging.ILogger, rl *ratelimiter.RateLimiter, repo ratelimiter.ISettingsRepository) *LoadBalancer {
+	lb := &LoadBalancer{
 		logger: l,
 		rl:     rl,
 		repo:   repo,
 	}
+	lb.upstreams.Store(&upstreams)
+
+	return lb
+}
+
+// Upstreams возвращает текущие пулы по именам
+func (lb *LoadBalancer) Upstreams() map[string]*Upstream {
+	return *lb.upstreams.Load()
+}
+
+// Upstream возвращает пул по имени
+func (lb *LoadBalancer) Upstream(name string) (*Upstream, bool) {
+	up, ok := lb.Upstreams()[name]
+	return up, ok
+}
+
+// RoutesUpdate - скомпилированная таблица маршрутов вместе с набором пулов, на которые она ссылается.
+// Создается PrepareRoutes и применяется ApplyRoutes целиком
+type RoutesUpdate struct {
+	upstreams map[string]*Upstream
+	table     *routeTable
+	lbMethod  string
+}
+
+// PrepareRoutes компилирует маршруты для набора пулов upstreams и создает для каждого балансировщик
+// по lb_method маршрута (или server.lb_method). Состояние балансировщика при этом не меняется,
+// поэтому ошибка компиляции не оставляет конфиг примененным наполовину
+func (lb *LoadBalancer) PrepareRoutes(srv *config.Server, routes []config.Route, upstreams map[string]*Upstream) (*RoutesUpdate, error) {
+	table, err := newRouteTable(srv, routes, upstreams, lb.routes.Load(), lb.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RoutesUpdate{
+		upstreams: upstreams,
+		table:     table,
+		lbMethod:  srv.LBMethod,
+	}, nil
+}
+
+// ApplyRoutes атомарно подменяет таблицу маршрутов и набор пулов.
+// Запросы, которые уже выбирают бэкенд, дорабатывают со старыми балансировщиками.
+func (lb *LoadBalancer) ApplyRoutes(u *RoutesUpdate) {
+	lb.routes.Store(u.table)
+	lb.upstreams.Store(&u.upstreams)
+
+	lb.logger.Info("load balancing method was choosen", map[string]interface{}{
+		"lb_method": u.lbMethod,
+		"routes":    len(u.table.routes),
+	})
+}
+
+// SetRoutes компилирует и применяет маршруты для текущего набора пулов
+func (lb *LoadBalancer) SetRoutes(srv *config.Server, routes []config.Route) error {
+	u, err := lb.PrepareRoutes(srv, routes, lb.Upstreams())
+	if err != nil {
+		return err
+	}
+
+	lb.ApplyRoutes(u)
+
+	return nil
+}
+
+// SetRetry применяет настройки повторов запросов; бюджет при этом начинается заново
+func (lb *LoadBalancer) SetRetry(cfg config.Retry) {
+	lb.retry.Store(newRetryPolicy(cfg))
+
+	lb.logger.Info("retry policy was set", map[string]interface{}{
+		"attempts":        cfg.Attempts,
+		"retry_on_status": cfg.RetryOnStatus,
+		"budget_percent":  cfg.BudgetPercent,
+		"non_idempotent":  cfg.NonIdempotent,
+	})
+}
+
+// SetTimeouts применяет дедлайны запросов. Таймауты самого http.Server применяются только при запуске.
+func (lb *LoadBalancer) SetTimeouts(cfg config.ServerTimeouts) {
+	lb.timeouts.Store(&cfg)
 }
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func TestSetRoutesRejectsUnknownLBMethod(t *testing.T) {
+	pool := config.BackendPool{URLs: []string{"http://127.0.0.1:8081"}}
+
+	tests := []struct {
+		name   string
+		srv    config.Server
+		routes []config.Route
+	}{
+		{name: "server", srv: config.Server{LBMethod: "XYZ"}},
+		{
+			name:   "route",
+			srv:    config.Server{LBMethod: "RR"},
+			routes: []config.Route{{Name: "api", PathPrefix: "/api", LBMethod: "XYZ"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := NewUpstream(config.DefaultPool, pool, testLogger)
+			lb := NewLoadBalancer(map[string]*Upstream{config.DefaultPool: up}, testLogger, nil, nil)
+
+			// Неизвестный алгоритм - ошибка старта, а не молчаливый откат к алгоритму по умолчанию
+			if err := lb.SetRoutes(&tt.srv, tt.routes); err == nil {
+				t.Fatal("SetRoutes accepted unknown lb_method")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	activeConnectionsDesc = prometheus.NewDesc(
+		"lb_backend_active_connections",
+		"Active connections to the backend.",
+		[]string{"pool", "backend"}, nil,
+	)
+	backendUpDesc = prometheus.NewDesc(
+		"lb_backend_up",
+		"Whether the backend is alive according to health checks (1) or not (0).",
+		[]string{"pool", "backend"}, nil,
+	)
+	bucketsDesc = prometheus.NewDesc(
+		"lb_ratelimiter_buckets",
+		"Number of live rate limiter buckets.",
+		nil, nil,
+	)
+)
+
+// stateCollector снимает состояние пулов и rate limiter в момент сбора метрик,
+// поэтому удаленные бэкенды и пулы сразу пропадают из вывода
+type stateCollector struct {
+	lb *LoadBalancer
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeConnectionsDesc
+	ch <- backendUpDesc
+	ch <- bucketsDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, up := range c.lb.Upstreams() {
+		for _, b := range up.Pool.List() {
+			url := b.URL.String()
+			ch <- prometheus.MustNewConstMetric(activeConnectionsDesc, prometheus.GaugeValue, float64(atomic.LoadInt32(&b.ActiveConnections)), name, url)
+
+			alive := 0.0
+			if b.IsAlive() {
+				alive = 1
+			}
+			ch <- prometheus.MustNewConstMetric(backendUpDesc, prometheus.GaugeValue, alive, name, url)
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(bucketsDesc, prometheus.GaugeValue, float64(c.lb.rl.BucketsCount()))
+}
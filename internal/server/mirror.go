@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// mirror отправляет копии запросов маршрута в теневой пул. Теневые запросы выполняются в фоне,
+// их ответы и ошибки отбрасываются и никак не влияют на основной запрос
+type mirror struct {
+	cfg      config.Mirror
+	upstream *Upstream
+	method   *lbMethod
+	// sem ограничивает число одновременных теневых запросов; если он заполнен, запрос не зеркалируется
+	sem    chan struct{}
+	logger logging.ILogger
+}
+
+func newMirror(cfg config.Mirror, srv *config.Server, upstreams map[string]*Upstream, l logging.ILogger) (*mirror, error) {
+	up, ok := upstreams[cfg.Pool]
+	if !ok {
+		return nil, fmt.Errorf("unknown pool %q", cfg.Pool)
+	}
+
+	b, err := balancer.New(srv.LBMethod, srv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mirror{
+		cfg:      cfg,
+		upstream: up,
+		method:   &lbMethod{name: srv.LBMethod, balancer: b},
+		sem:      make(chan struct{}, cfg.MaxConcurrent),
+		logger:   l,
+	}, nil
+}
+
+// sample решает, зеркалировать ли очередной запрос
+func (m *mirror) sample() bool {
+	return rand.Float64()*100 < m.cfg.Percent
+}
+
+// send копирует запрос с уже прочитанным телом и отправляет его в теневой пул в отдельной горутине.
+// Копия делается до возврата, так как основной запрос может завершиться раньше теневого
+func (m *mirror) send(r *http.Request, body []byte, requestID string) {
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		m.logger.Debug("mirror is saturated, request was not mirrored", map[string]interface{}{
+			"pool":       m.upstream.Name,
+			"request_id": requestID,
+		})
+		return
+	}
+
+	// Теневой запрос не должен отменяться вместе с основным, поэтому у него свой дедлайн
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if m.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(r.Context()), m.cfg.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.WithoutCancel(r.Context()))
+	}
+	req := r.Clone(ctx)
+	req.Header.Set(m.cfg.Header, "true")
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	go func() {
+		defer func() { <-m.sem }()
+		defer cancel()
+
+		b := m.method.balancer.Pick(req, m.upstream.Pool.List(), nil)
+		if b == nil {
+			m.logger.Debug("no available backends in mirror pool", map[string]interface{}{
+				"pool":       m.upstream.Name,
+				"request_id": requestID,
+			})
+			return
+		}
+
+		b.AddConnection()
+		defer b.ConnectionDone()
+
+		rec := &discardWriter{header: make(http.Header), status: http.StatusOK}
+		start := time.Now()
+		// Латентность теневых запросов не учитывается в EWMA: она не должна влиять на выбор бэкенда
+		// для основного трафика
+		b.Proxy.ServeHTTP(rec, req)
+
+		m.logger.Debug("request was mirrored", map[string]interface{}{
+			"pool":       m.upstream.Name,
+			"host":       b.URL.String(),
+			"status":     rec.status,
+			"latency":    time.Since(start).String(),
+			"request_id": requestID,
+		})
+	}()
+}
+
+// discardWriter принимает ответ теневого бэкенда и отбрасывает его, запоминая только код
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (d *discardWriter) WriteHeader(code int) {
+	d.status = code
+}
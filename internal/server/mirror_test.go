@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMirrorExplicitZeros(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		method string
+		body   string
+		want   config.Mirror
+		// mirrored - должен ли запрос попасть в теневой пул
+		mirrored bool
+	}{
+		{
+			name:     "defaults",
+			yaml:     "pool: default\npercent: 100",
+			method:   http.MethodPost,
+			body:     "payload",
+			want:     config.Mirror{Pool: "default", Percent: 100, MaxBodySize: 65536, Header: "X-Mirrored-Request", Timeout: 10 * time.Second, MaxConcurrent: 100},
+			mirrored: true,
+		},
+		{
+			// Зеркалируются только запросы без тела
+			name:     "max_body_size 0 with body",
+			yaml:     "pool: default\npercent: 100\nmax_body_size: 0",
+			method:   http.MethodPost,
+			body:     "payload",
+			want:     config.Mirror{Pool: "default", Percent: 100, Header: "X-Mirrored-Request", Timeout: 10 * time.Second, MaxConcurrent: 100},
+			mirrored: false,
+		},
+		{
+			name:     "max_body_size 0 without body",
+			yaml:     "pool: default\npercent: 100\nmax_body_size: 0",
+			method:   http.MethodGet,
+			want:     config.Mirror{Pool: "default", Percent: 100, Header: "X-Mirrored-Request", Timeout: 10 * time.Second, MaxConcurrent: 100},
+			mirrored: true,
+		},
+		{
+			name:     "timeout 0",
+			yaml:     "pool: default\npercent: 100\ntimeout: 0s",
+			method:   http.MethodGet,
+			want:     config.Mirror{Pool: "default", Percent: 100, MaxBodySize: 65536, Header: "X-Mirrored-Request", MaxConcurrent: 100},
+			mirrored: true,
+		},
+		{
+			name:     "max_concurrent 0",
+			yaml:     "pool: default\npercent: 100\nmax_concurrent: 0",
+			method:   http.MethodGet,
+			want:     config.Mirror{Pool: "default", Percent: 100, MaxBodySize: 65536, Header: "X-Mirrored-Request", Timeout: 10 * time.Second},
+			mirrored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m config.Mirror
+			if err := yaml.Unmarshal([]byte(tt.yaml), &m); err != nil {
+				t.Fatal(err)
+			}
+			if m != tt.want {
+				t.Fatalf("decoded %+v, want %+v", m, tt.want)
+			}
+
+			var hits atomic.Int32
+			shadow := make(chan struct{}, 1)
+			srv := countingBackend(t, &hits, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Mirrored-Request") == "true" {
+					shadow <- struct{}{}
+				}
+			})
+			lb := newTestLoadBalancer(t, config.Server{LBMethod: "RR"}, config.BackendPool{URLs: []string{srv.URL}},
+				config.Route{PathPrefix: "/", Mirror: &m})
+
+			if w := serve(lb, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))); w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+
+			// Теневой запрос отправляется в фоне; если зеркалирование отклонено, горутина не запускается вовсе
+			if tt.mirrored {
+				select {
+				case <-shadow:
+				case <-time.After(time.Second):
+					t.Fatal("request was not mirrored")
+				}
+			} else if len(shadow) != 0 || hits.Load() != 1 {
+				t.Fatalf("request was mirrored: backend got %d requests", hits.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/accesslog"
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
+	"github.com/dielit66/cloud-camp-tt/pkg/errors"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServeProxy - общий обработчик для всех алгоритмов балансировки:
+// находит маршрут запроса, выбирает бэкенд его пула через балансировщик маршрута,
+// ведет учет соединений и латентности, логирует и проксирует запрос.
+// При ошибке соединения (и, если настроено, при определенных кодах ответа) запрос повторяется на другом бэкенде.
+func (lb *LoadBalancer) ServeProxy(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	rt := lb.routes.Load().match(r)
+	up, method, stats := rt.target(w, r)
+	retry := lb.retry.Load()
+	retry.recordRequest()
+
+	// Общий дедлайн запроса, включая все повторы
+	if timeout := lb.timeouts.Load().RequestTimeout(r.URL.Path); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	// Для сплитов учитывается итог запроса целиком, включая повторы и ошибки балансировщика
+	if stats != nil {
+		rec := newStatusRecorder(w)
+		start := time.Now()
+		defer func() {
+			stats.observe(rec.status, time.Since(start))
+		}()
+		w = rec
+	}
+
+	lb.logger.Debug("new request", map[string]interface{}{
+		"client_ip":  r.RemoteAddr,
+		"pool":       up.Name,
+		"lb_method":  method.name,
+		"request_id": requestID,
+		"time":       time.Now().Format(time.RFC3339),
+	})
+
+	// Путь меняется после выбора маршрута, но до проксирования
+	r = rt.rewrite(r)
+
+	// Тело буферизуется только если запрос вообще можно повторять или он будет зеркалироваться
+	canReplay := retry.eligible(r)
+	mirrored := rt.mirror != nil && rt.mirror.sample()
+	var body []byte
+	if canReplay || mirrored {
+		var limit int64
+		if canReplay {
+			limit = retry.cfg.MaxBodySize
+		}
+		if mirrored {
+			limit = max(limit, rt.mirror.cfg.MaxBodySize)
+		}
+
+		buf, ok, err := bufferBody(r, limit)
+		if err != nil {
+			lb.logger.Warn("failed to read request body", map[string]interface{}{
+				"client_ip":  r.RemoteAddr,
+				"request_id": requestID,
+				"error":      err.Error(),
+			})
+			writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "Failed to read request body"))
+			return
+		}
+		body = buf
+		canReplay = canReplay && ok && int64(len(buf)) <= retry.cfg.MaxBodySize
+		mirrored = mirrored && ok && int64(len(buf)) <= rt.mirror.cfg.MaxBodySize
+	}
+
+	if mirrored {
+		rt.mirror.send(r, body, requestID)
+	}
+
+	tried := make(balancer.Exclude)
+	var lastErr error
+	attempts := 0
+
+	for attempt := 0; ; attempt++ {
+		b, permit := pick(r, method, up.Pool, tried)
+		if b == nil {
+			break
+		}
+
+		// Ответ с кодом из retry_on_status отбрасывается, только если запрос есть куда повторить,
+		// иначе клиент получает последний ответ бэкенда, а не ошибку балансировщика
+		willRetry := canReplay && attempt < retry.cfg.Attempts && retry.canRetry() && hasCandidate(up.Pool, tried)
+
+		a := &backend.ProxyAttempt{}
+		if willRetry {
+			a.RetryStatus = retry.retryStatus
+		}
+		req := r.WithContext(backend.WithProxyAttempt(r.Context(), a))
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		lb.proxyTo(w, req, up, b, permit, a, method.name, requestID)
+		attempts++
+
+		if a.Err == nil {
+			return
+		}
+		lastErr = a.Err
+
+		// Если истек общий дедлайн, повторять бессмысленно
+		if !willRetry || r.Context().Err() != nil {
+			break
+		}
+		retry.recordRetry()
+
+		lb.logger.Warn("request to backend failed, retrying on another backend", map[string]interface{}{
+			"client_ip":  r.RemoteAddr,
+			"host":       b.URL.String(),
+			"attempt":    attempt + 1,
+			"error":      a.Err.Error(),
+			"lb_method":  method.name,
+			"request_id": requestID,
+		})
+	}
+
+	if lastErr != nil {
+		lb.logger.Error("request to backend failed", map[string]interface{}{
+			"client_ip":  r.RemoteAddr,
+			"attempts":   attempts,
+			"error":      lastErr.Error(),
+			"lb_method":  method.name,
+			"request_id": requestID,
+			"time":       time.Now().Format(time.RFC3339),
+		})
+		if isTimeout(lastErr) {
+			writeAPIError(w, errors.NewAPIError(http.StatusGatewayTimeout, "Sorry, the service took too long to respond. Please try again later."))
+			return
+		}
+		writeAPIError(w, errors.NewAPIError(http.StatusBadGateway, "Sorry, the service failed to process the request. Please try again later."))
+		return
+	}
+
+	lb.logger.Error("all backends are down", map[string]interface{}{
+		"client_ip":  r.RemoteAddr,
+		"pool":       up.Name,
+		"lb_method":  method.name,
+		"request_id": requestID,
+		"time":       time.Now().Format(time.RFC3339),
+	})
+	writeAPIError(w, errors.NewAPIError(http.StatusServiceUnavailable, "Sorry, the service is currently unavailable. Please try again later."))
+}
+
+// proxyTo выполняет одну попытку проксирования на бэкенд b и учитывает ее результат
+// в счетчиках соединений, латентности, circuit breaker и outlier detection
+func (lb *LoadBalancer) proxyTo(w http.ResponseWriter, r *http.Request, up *Upstream, b *backend.Backend, permit backend.Permit, a *backend.ProxyAttempt, lbMethod, requestID string) {
+	lb.logger.Debug("backend was chosen", map[string]interface{}{
+		"client_ip":          r.RemoteAddr,
+		"host":               b.URL.String(),
+		"active_connections": atomic.LoadInt32(&b.ActiveConnections),
+		"lb_method":          lbMethod,
+		"request_id":         requestID,
+		"time":               time.Now().Format(time.RFC3339),
+	})
+
+	b.AddConnection()
+	defer b.ConnectionDone()
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "proxy "+up.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLFull(b.URL.String()+r.URL.Path),
+			attribute.String("lb.pool", up.Name),
+			attribute.String("lb.method", lbMethod),
+			tracing.RequestIDKey.String(requestID),
+		),
+	)
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	rec := newStatusRecorder(w)
+	start := time.Now()
+	b.Proxy.ServeHTTP(rec, r)
+	latency := time.Since(start)
+	accesslog.FromContext(r.Context()).SetUpstream(b.URL.Host, latency)
+
+	if a.Err != nil {
+		tracing.RecordError(span, a.Err)
+	} else {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+	}
+
+	success := a.Err == nil && rec.status < http.StatusInternalServerError
+	// Латентность неудачных попыток не учитывается в EWMA, вместо нее засчитывается штраф
+	if success {
+		b.ObserveLatency(latency)
+	} else {
+		b.ObserveFailure()
+	}
+	b.Breaker.Done(permit, success)
+	up.Outlier.Observe(b, success)
+	b.ObserveRequest(success)
+
+	status := attemptStatus(rec.status, a.Err)
+	metrics.Requests.WithLabelValues(up.Name, b.URL.String(), r.Method, status).Inc()
+	metrics.RequestDuration.WithLabelValues(up.Name, b.URL.String(), r.Method, status).Observe(latency.Seconds())
+
+	if a.Err != nil {
+		return
+	}
+
+	lb.logger.Debug("request was proxied", map[string]interface{}{
+		"client_ip":  r.RemoteAddr,
+		"host":       b.URL.String(),
+		"latency":    latency.String(),
+		"status":     rec.status,
+		"lb_method":  lbMethod,
+		"request_id": requestID,
+		"time":       time.Now().Format(time.RFC3339),
+	})
+}
+
+// pick выбирает бэкенд, на который запрос еще не отправлялся, и резервирует у его circuit breaker
+// место для запроса. Если пробные слоты half-open успели занять другие запросы, выбирается другой бэкенд.
+// Балансировщик получает полный список пула: отфильтрованный список заставил бы CH перестраивать кольцо
+func pick(r *http.Request, method *lbMethod, pool *backend.Pool, tried balancer.Exclude) (*backend.Backend, backend.Permit) {
+	backends := pool.List()
+	for {
+		b := method.balancer.Pick(r, backends, tried)
+		if b == nil {
+			return nil, backend.Permit{}
+		}
+		tried[b] = true
+
+		if permit, ok := b.Breaker.TryAcquire(); ok {
+			return b, permit
+		}
+	}
+}
+
+// hasCandidate сообщает, что в пуле остался доступный бэкенд, который еще не пробовали для запроса
+func hasCandidate(pool *backend.Pool, tried balancer.Exclude) bool {
+	for _, b := range pool.List() {
+		if tried.Allows(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// attemptStatus возвращает метку status для метрик попытки: код ответа бэкенда или error,
+// если ответа не было
+func attemptStatus(code int, err error) string {
+	if err == nil {
+		return strconv.Itoa(code)
+	}
+
+	var statusErr *backend.RetryableStatusError
+	if stderrors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.Code)
+	}
+	return "error"
+}
+
+// isTimeout сообщает, что попытка упала по таймауту (дедлайн запроса, dial, TLS или ожидание заголовков ответа)
+func isTimeout(err error) bool {
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout()
+}
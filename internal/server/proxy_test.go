@@ -0,0 +1,194 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+)
+
+var testLogger = logging.NewZeroLoggerWithWriter(4, io.Discard)
+
+// newTestLoadBalancer создает балансировщик с пулом default из pool и маршрутами routes
+func newTestLoadBalancer(t *testing.T, srv config.Server, pool config.BackendPool, routes ...config.Route) *LoadBalancer {
+	t.Helper()
+
+	up := NewUpstream(config.DefaultPool, pool, testLogger)
+	lb := NewLoadBalancer(map[string]*Upstream{config.DefaultPool: up}, testLogger, nil, nil)
+	if err := lb.SetRoutes(&srv, routes); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	lb.SetRetry(srv.Retry)
+	lb.SetTimeouts(srv.Timeouts)
+	return lb
+}
+
+// serve проксирует запрос через lb так же, как основной обработчик сервера
+func serve(lb *LoadBalancer, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	middleware.WithRequestID(http.HandlerFunc(lb.ServeProxy)).ServeHTTP(w, r)
+	return w
+}
+
+// countingBackend - тестовый бэкенд, который считает запросы и отвечает handler
+func countingBackend(t *testing.T, hits *atomic.Int32, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEWMAAvoidsFastFailingBackend(t *testing.T) {
+	var failing, healthy atomic.Int32
+	failingSrv := countingBackend(t, &failing, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	healthySrv := countingBackend(t, &healthy, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	lb := newTestLoadBalancer(t, config.Server{LBMethod: "EWMA"},
+		config.BackendPool{URLs: []string{failingSrv.URL, healthySrv.URL}})
+
+	// Пока замеров нет, оба бэкенда равны; после первой ошибки мгновенный 500 проигрывает медленному 200
+	for i := 0; i < 20; i++ {
+		serve(lb, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if failing.Load() > 1 {
+		t.Fatalf("fast failing backend got %d of 20 requests, healthy one got %d", failing.Load(), healthy.Load())
+	}
+}
+
+func TestRetryOnStatusKeepsLastResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends int
+		// hits - сколько попыток ожидается всего
+		hits int32
+	}{
+		// Повторять некуда: ответ 503 возвращается клиенту как есть
+		{name: "single backend", backends: 1, hits: 1},
+		// Второй бэкенд тоже ответил 503, третьего нет: клиент получает этот ответ
+		{name: "all backends", backends: 2, hits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			urls := make([]string, 0, tt.backends)
+			for i := 0; i < tt.backends; i++ {
+				srv := countingBackend(t, &hits, func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Retry-After", "7")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte("upstream maintenance"))
+				})
+				urls = append(urls, srv.URL)
+			}
+
+			lb := newTestLoadBalancer(t, config.Server{
+				LBMethod: "RR",
+				Retry: config.Retry{
+					Attempts:      3,
+					RetryOnStatus: []int{http.StatusServiceUnavailable},
+					BudgetPercent: 100,
+				},
+			}, config.BackendPool{URLs: urls})
+
+			w := serve(lb, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusServiceUnavailable || w.Body.String() != "upstream maintenance" || w.Header().Get("Retry-After") != "7" {
+				t.Fatalf("got %d %q (Retry-After %q), want the upstream 503 response", w.Code, w.Body.String(), w.Header().Get("Retry-After"))
+			}
+			if hits.Load() != tt.hits {
+				t.Fatalf("backends got %d attempts, want %d", hits.Load(), tt.hits)
+			}
+		})
+	}
+}
+
+func TestUpstreamTimeout(t *testing.T) {
+	// slowHandler отвечает позже всех таймаутов теста, но не держит сервер после отмены запроса
+	slowHandler := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		retry    config.Retry
+		timeouts config.ServerTimeouts
+		// fallback - есть ли быстрый бэкенд, на который можно повторить запрос
+		fallback bool
+		want     int
+		slowHits int32
+		fastHits int32
+	}{
+		{name: "no retries", method: http.MethodGet, want: http.StatusGatewayTimeout, slowHits: 1},
+		{
+			name:     "retried on another backend",
+			method:   http.MethodGet,
+			retry:    config.Retry{Attempts: 1, BudgetPercent: 100},
+			fallback: true,
+			want:     http.StatusOK,
+			slowHits: 1,
+			fastHits: 1,
+		},
+		{
+			// Неидемпотентный запрос после таймаута не повторяется
+			name:     "non idempotent",
+			method:   http.MethodPost,
+			retry:    config.Retry{Attempts: 1, BudgetPercent: 100},
+			fallback: true,
+			want:     http.StatusGatewayTimeout,
+			slowHits: 1,
+		},
+		{
+			// Истек общий дедлайн запроса: повторять бессмысленно
+			name:     "request deadline",
+			method:   http.MethodGet,
+			retry:    config.Retry{Attempts: 1, BudgetPercent: 100},
+			timeouts: config.ServerTimeouts{Request: 50 * time.Millisecond},
+			fallback: true,
+			want:     http.StatusGatewayTimeout,
+			slowHits: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var slow, fast atomic.Int32
+			// RoundRobin начинает со второго бэкенда, поэтому первая попытка идет на медленный
+			urls := []string{countingBackend(t, &slow, slowHandler).URL}
+			if tt.fallback {
+				fastSrv := countingBackend(t, &fast, func(w http.ResponseWriter, r *http.Request) {})
+				urls = []string{fastSrv.URL, urls[0]}
+			}
+
+			pool := config.BackendPool{URLs: urls}
+			if tt.timeouts.Request == 0 {
+				pool.Timeouts.ResponseHeader = 50 * time.Millisecond
+			}
+			lb := newTestLoadBalancer(t, config.Server{LBMethod: "RR", Retry: tt.retry, Timeouts: tt.timeouts}, pool)
+
+			w := serve(lb, httptest.NewRequest(tt.method, "/", nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if slow.Load() != tt.slowHits || fast.Load() != tt.fastHits {
+				t.Fatalf("slow backend got %d attempts, fast %d; want %d and %d", slow.Load(), fast.Load(), tt.slowHits, tt.fastHits)
+			}
+		})
+	}
+}
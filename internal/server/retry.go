@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+// retryBudgetWindow - окно, за которое считается доля повторов
+const retryBudgetWindow = 10 * time.Second
+
+// retryPolicy - настройки повторов и бюджет, общий для всех запросов
+type retryPolicy struct {
+	cfg config.Retry
+
+	mux         sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func newRetryPolicy(cfg config.Retry) *retryPolicy {
+	return &retryPolicy{
+		cfg:         cfg,
+		windowStart: time.Now(),
+	}
+}
+
+// eligible проверяет, можно ли в принципе повторять запрос с таким методом
+func (rp *retryPolicy) eligible(r *http.Request) bool {
+	if rp.cfg.Attempts == 0 {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return rp.cfg.NonIdempotent
+	}
+}
+
+func (rp *retryPolicy) retryStatus(code int) bool {
+	return slices.Contains(rp.cfg.RetryOnStatus, code)
+}
+
+// recordRequest учитывает новый входящий запрос в бюджете
+func (rp *retryPolicy) recordRequest() {
+	rp.mux.Lock()
+	defer rp.mux.Unlock()
+
+	rp.rotate()
+	rp.requests++
+}
+
+// canRetry проверяет, что повтор укладывается в бюджет, не расходуя его
+func (rp *retryPolicy) canRetry() bool {
+	rp.mux.Lock()
+	defer rp.mux.Unlock()
+
+	rp.rotate()
+	limit := float64(rp.requests) * rp.cfg.BudgetPercent / 100
+	floor := float64(rp.cfg.MinRetriesPerSecond) * retryBudgetWindow.Seconds()
+
+	return float64(rp.retries) < max(limit, floor)
+}
+
+func (rp *retryPolicy) recordRetry() {
+	rp.mux.Lock()
+	defer rp.mux.Unlock()
+
+	rp.rotate()
+	rp.retries++
+}
+
+func (rp *retryPolicy) rotate() {
+	if time.Since(rp.windowStart) > retryBudgetWindow {
+		rp.windowStart = time.Now()
+		rp.requests, rp.retries = 0, 0
+	}
+}
+
+// bufferBody читает тело запроса в память, чтобы его можно было отправить повторно.
+// Если тело больше limit, возвращает false и восстанавливает r.Body для однократной отправки.
+func bufferBody(r *http.Request, limit int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if int64(len(buf)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return nil, false, nil
+	}
+
+	r.Body.Close()
+	return buf, true, nil
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// route - скомпилированное правило маршрутизации с пулом и собственным балансировщиком
+type route struct {
+	cfg       config.Route
+	pathRegex *regexp.Regexp
+	upstream  *Upstream
+	method    *lbMethod
+	// split - canary-разделение трафика маршрута (может быть nil)
+	split *split
+	// mirror - зеркалирование запросов маршрута в теневой пул (может быть nil)
+	mirror *mirror
+}
+
+// routeTable - маршруты в порядке проверки (от самого длинного path_prefix) и маршрут по умолчанию (пул из блока pool)
+type routeTable struct {
+	routes   []*route
+	fallback *route
+}
+
+// newRouteTable компилирует маршруты из конфигурации. Для каждого маршрута создается
+// отдельный балансировщик, так как алгоритмы хранят состояние по своему списку бэкендов.
+// Сплиты, настройки которых не изменились, переносятся из prev вместе с весом, заданным через API, и статистикой
+func newRouteTable(srv *config.Server, routes []config.Route, upstreams map[string]*Upstream, prev *routeTable, l logging.ILogger) (*routeTable, error) {
+	fallback, err := newRoute(config.Route{}, srv, upstreams)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &routeTable{
+		routes:   make([]*route, 0, len(routes)),
+		fallback: fallback,
+	}
+	for _, rc := range routes {
+		rt, err := newRoute(rc, srv, upstreams)
+		if err != nil {
+			return nil, err
+		}
+		if rc.Canary != nil {
+			if rt.split = prev.reusableSplit(rc, upstreams); rt.split == nil {
+				if rt.split, err = newSplit(rc.Name, *rc.Canary, rc.Balancing(srv), upstreams); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if rc.Mirror != nil {
+			if rt.mirror, err = newMirror(*rc.Mirror, rc.Balancing(srv), upstreams, l); err != nil {
+				return nil, err
+			}
+		}
+		table.routes = append(table.routes, rt)
+	}
+
+	// Из подошедших маршрутов выигрывает самый длинный path_prefix, при равной длине - первый в конфиге
+	sort.SliceStable(table.routes, func(i, j int) bool {
+		return len(table.routes[i].cfg.PathPrefix) > len(table.routes[j].cfg.PathPrefix)
+	})
+
+	return table, nil
+}
+
+func newRoute(rc config.Route, srv *config.Server, upstreams map[string]*Upstream) (*route, error) {
+	up, ok := upstreams[rc.PoolName()]
+	if !ok {
+		return nil, fmt.Errorf("unknown pool %q", rc.PoolName())
+	}
+
+	cfg := rc.Balancing(srv)
+	b, err := balancer.New(cfg.LBMethod, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &route{
+		cfg:      rc,
+		upstream: up,
+		method:   &lbMethod{name: cfg.LBMethod, balancer: b},
+	}
+	if rc.PathRegex != "" {
+		if rt.pathRegex, err = regexp.Compile(rc.PathRegex); err != nil {
+			return nil, err
+		}
+	}
+
+	return rt, nil
+}
+
+// reusableSplit возвращает сплит маршрута с тем же именем, если его пулы и настройки балансировки не изменились
+func (t *routeTable) reusableSplit(rc config.Route, upstreams map[string]*Upstream) *split {
+	if t == nil {
+		return nil
+	}
+
+	for _, rt := range t.routes {
+		if rt.split == nil || rt.cfg.Name != rc.Name {
+			continue
+		}
+		if rt.split.canary == upstreams[rc.Canary.Pool] && rt.upstream == upstreams[rc.PoolName()] &&
+			reflect.DeepEqual(rt.cfg.Canary, rc.Canary) && rt.cfg.LBMethod == rc.LBMethod && reflect.DeepEqual(rt.cfg.Hash, rc.Hash) {
+			return rt.split
+		}
+	}
+	return nil
+}
+
+// match возвращает первый маршрут, под который подходит запрос, или маршрут по умолчанию
+func (t *routeTable) match(r *http.Request) *route {
+	for _, rt := range t.routes {
+		if rt.matches(r) {
+			return rt
+		}
+	}
+	return t.fallback
+}
+
+func (rt *route) matches(r *http.Request) bool {
+	if rt.cfg.Host != "" && !matchHost(rt.cfg.Host, r.Host) {
+		return false
+	}
+	if rt.cfg.PathPrefix != "" && !matchPrefix(rt.cfg.PathPrefix, r.URL.Path) {
+		return false
+	}
+	if rt.pathRegex != nil && !rt.pathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	if len(rt.cfg.Methods) > 0 && !containsFold(rt.cfg.Methods, r.Method) {
+		return false
+	}
+	for name, value := range rt.cfg.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// target выбирает пул и балансировщик для запроса с учетом canary-сплита.
+// Для маршрутов со сплитом возвращается также статистика выбранного варианта
+func (rt *route) target(w http.ResponseWriter, r *http.Request) (*Upstream, *lbMethod, *splitStats) {
+	if rt.split == nil {
+		return rt.upstream, rt.method, nil
+	}
+	if rt.split.useCanary(w, r) {
+		return rt.split.canary, rt.split.method, &rt.split.canaryStats
+	}
+	return rt.upstream, rt.method, &rt.split.stableStats
+}
+
+// rewrite возвращает копию запроса с путем, измененным по strip_prefix или rewrite маршрута
+func (rt *route) rewrite(r *http.Request) *http.Request {
+	path := r.URL.Path
+	switch {
+	case rt.cfg.StripPrefix:
+		path = strings.TrimPrefix(path, rt.cfg.PathPrefix)
+	case rt.cfg.Rewrite != "" && rt.pathRegex != nil:
+		path = rt.pathRegex.ReplaceAllString(path, rt.cfg.Rewrite)
+	case rt.cfg.Rewrite != "":
+		path = rt.cfg.Rewrite + strings.TrimPrefix(path, rt.cfg.PathPrefix)
+	default:
+		return r
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+
+	req := new(http.Request)
+	*req = *r
+	req.URL = &u
+	return req
+}
+
+// matchPrefix проверяет префикс по границе сегментов: /api подходит для /api и /api/users, но не для /apiv2.
+// Префикс с завершающим / (/api/) не подходит для самого /api
+func matchPrefix(prefix, path string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// matchHost сравнивает Host запроса (без порта) с именем хоста или шаблоном *.example.com
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
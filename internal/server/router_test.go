@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+func TestRouteMatch(t *testing.T) {
+	up := NewUpstream(config.DefaultPool, config.BackendPool{URLs: []string{"http://127.0.0.1:8081"}}, testLogger)
+	upstreams := map[string]*Upstream{config.DefaultPool: up}
+	routes := []config.Route{
+		{Name: "shop", Host: "shop.example.com", PathPrefix: "/"},
+		{Name: "wildcard", Host: "*.example.com", PathPrefix: "/"},
+		{Name: "api", PathPrefix: "/api"},
+		// Идет после /api, но выигрывает как более длинный префикс
+		{Name: "admin", PathPrefix: "/api/admin"},
+		{Name: "v2", PathPrefix: "/v2/"},
+	}
+	table, err := newRouteTable(&config.Server{LBMethod: "RR"}, routes, upstreams, nil, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		path string
+		// want - имя маршрута; пустое - маршрут по умолчанию
+		want string
+	}{
+		{host: "shop.example.com:8080", path: "/cart", want: "shop"},
+		{host: "SHOP.example.com", path: "/cart", want: "shop"},
+		{host: "blog.example.com", path: "/cart", want: "wildcard"},
+		{host: "example.com", path: "/cart", want: ""},
+		{host: "lb", path: "/api", want: "api"},
+		{host: "lb", path: "/api/users", want: "api"},
+		{host: "lb", path: "/apiv2", want: ""},
+		{host: "lb", path: "/api/admin", want: "admin"},
+		{host: "lb", path: "/api/admin/users", want: "admin"},
+		{host: "lb", path: "/api/administrator", want: "api"},
+		{host: "shop.example.com", path: "/api/admin", want: "admin"},
+		{host: "lb", path: "/v2", want: ""},
+		{host: "lb", path: "/v2/users", want: "v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+			if got := table.match(r).cfg.Name; got != tt.want {
+				t.Fatalf("matched route %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteRewrite(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.RequestURI()
+	}))
+	defer srv.Close()
+
+	lb := newTestLoadBalancer(t, config.Server{LBMethod: "RR"}, config.BackendPool{URLs: []string{srv.URL}},
+		config.Route{PathPrefix: "/api", StripPrefix: true},
+		config.Route{PathPrefix: "/old", Rewrite: "/new"},
+		config.Route{PathPrefix: "/assets/", PathRegex: `^/assets/v[0-9]+/(.*)$`, Rewrite: "/static/$1"},
+	)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/users?page=2", want: "/users?page=2"},
+		{path: "/api", want: "/"},
+		{path: "/old/items", want: "/new/items"},
+		{path: "/assets/v3/app.js", want: "/static/app.js"},
+		// Маршрут по умолчанию путь не меняет
+		{path: "/apiv2/users", want: "/apiv2/users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if w := serve(lb, httptest.NewRequest(http.MethodGet, tt.path, nil)); w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if got := <-paths; got != tt.want {
+				t.Fatalf("upstream got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,49 +8,85 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/dielit66/cloud-camp-tt/internal/accesslog"
+	"github.com/dielit66/cloud-camp-tt/internal/admin"
 	"github.com/dielit66/cloud-camp-tt/internal/config"
 	"github.com/dielit66/cloud-camp-tt/internal/healthcheck"
+	"github.com/dielit66/cloud-camp-tt/internal/metrics"
+	"github.com/dielit66/cloud-camp-tt/internal/netutil"
 	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
 	errors_middleware "github.com/dielit66/cloud-camp-tt/pkg/errors/middleware"
 	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
 )
 
-func (lb *LoadBalancer) StartServer(cfg *config.Server) error {
+func (lb *LoadBalancer) StartServer(c *config.Config) error {
+	cfg := &c.Server
+
 	// Создаем новый HTTP-мультиплексор для маршрутизации запросов
 	mux := http.NewServeMux()
 
-	// Определяем метод балансировки нагрузки на основе конфигурации
-	var LBMethod http.HandlerFunc
-	switch cfg.LBMethod {
-
-	case "RR":
-		lb.logger.Info("Round Robin method for load balancer was choosen", nil)
-		LBMethod = lb.LBRoundRobinMethod
-
-	case "LC":
-		lb.logger.Info("Least Connections method for load balancer was choosen", nil)
-		LBMethod = lb.LBLeastConnectionsMethod
-
-	default:
-		lb.logger.Info("Round Robin method for load balancer was choosen", nil)
-		LBMethod = lb.LBRoundRobinMethod
+	// Собираем маршруты и алгоритмы балансировки на основе конфигурации; неизвестный метод - ошибка запуска
+	if err := lb.SetRoutes(cfg, c.Routes); err != nil {
+		lb.logger.Error("failed to create load balancing method", map[string]interface{}{
+			"lb_method": cfg.LBMethod,
+			"error":     err.Error(),
+		})
+		return err
 	}
 
+	lb.SetRetry(cfg.Retry)
+	lb.SetTimeouts(cfg.Timeouts)
+
 	// Основной маршрут для load balancer
-	mux.HandleFunc("/", LBMethod)
+	mux.HandleFunc("/", lb.ServeProxy)
 	// - /healthcheck для проверки состояния load balancer'а
 	mux.HandleFunc("/healthcheck", healthcheck.HealthCheckHandler)
-	mux.HandleFunc("/api/ratelimit/config", lb.handleRateLimitConfig)
-	mux.HandleFunc("/api/ratelimit/config/", lb.handleRateLimitConfig)
+
+	// Admin API доступно только на отдельном listener'е admin.listen, требует аутентификации
+	// и пишет изменения в audit log. Без токенов и mTLS оно не запускается
+	var adminServer *http.Server
+	if c.Admin.AuthEnabled() {
+		audit := admin.NewAuditor(c.Admin.AuditLog, lb.logger)
+		defer audit.Close()
+		guard := adminGuard(admin.NewAuthenticator(c.Admin), audit)
+
+		var err error
+		if adminServer, err = lb.startAdmin(c.Admin, guard, cfg.Timeouts); err != nil {
+			return err
+		}
+	} else {
+		lb.logger.Warn("admin API is disabled, configure admin.tokens or admin.tls.client_ca_file", nil)
+	}
+
+	// - /metrics для сбора метрик Prometheus
+	if err := metrics.Register(&stateCollector{lb: lb}); err != nil {
+		lb.logger.Error("failed to register metrics collector", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	mux.Handle("/metrics", metrics.Handler())
 
 	// Оборачиваем в middleware для RequestID (сделал для логгирования и дебага по конкретному запросу), обработки ошибок и rate limiter
-	handler := middleware.WithRequestID(mux)
-	handler = errors_middleware.ErrorHandler(handler)
-	handler = ratelimiter.NewRateLimiterHandler(lb.rl, lb.logger)(handler)
+	handler := errors_middleware.ErrorHandler(mux)
+	handler = ratelimiter.NewRateLimiterHandler(lb.rl, c.RateLimiter.LegacyHeaders, lb.logger)(handler)
+	// Access log видит решение rate limiter и бэкенды, на которые ушел запрос
+	if c.AccessLog.Enabled {
+		accessLog := accesslog.New(c.AccessLog, netutil.ClientIP)
+		defer accessLog.Close()
+		handler = accessLog.Middleware(handler)
+	}
+	// Server span и RequestID создаются снаружи, чтобы решение rate limiter попадало в трейс
+	handler = tracing.Middleware(handler)
+	handler = middleware.WithRequestID(handler)
 
 	lb.server = &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: handler,
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
 	}
 
 	// Создаем контекст для обработки сигналов прерывания (SIGINT, SIGTERM)
@@ -80,6 +116,13 @@ func (lb *LoadBalancer) StartServer(cfg *config.Server) error {
 	defer cancel()
 
 	// Завершаем работу сервера, позволяя обработать текущие запросы.
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			lb.logger.Error("admin server shutdown returned error", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
 	if err := lb.server.Shutdown(ctx); err != nil {
 		lb.logger.Error("server shutdown returned error", map[string]interface{}{
 			"error": err.Error(),
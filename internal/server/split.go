@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/balancer"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/netutil"
+)
+
+// Значения заголовка принудительного выбора варианта
+const (
+	canaryAlways = "always"
+	canaryNever  = "never"
+)
+
+const (
+	defaultCanaryCookie = "lb_canary"
+	defaultCanaryHeader = "X-Canary"
+	// splitBuckets - число корзин, на которые делятся клиенты; вес задается в процентах
+	splitBuckets = 100
+)
+
+// split - canary-разделение трафика маршрута. Вес можно менять на лету через admin API,
+// статистика ведется отдельно для основного и canary пула
+type split struct {
+	route  string
+	cfg    config.Canary
+	canary *Upstream
+	method *lbMethod
+	weight atomic.Int32
+	// intN выбирает корзину для нового клиента при sticky: cookie; подменяется в тестах
+	intN func(n int) int
+
+	stableStats splitStats
+	canaryStats splitStats
+}
+
+// splitStats - счетчики запросов одного варианта сплита
+type splitStats struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+	// latency - суммарная длительность запросов в наносекундах
+	latency atomic.Int64
+}
+
+func newSplit(route string, cfg config.Canary, srv *config.Server, upstreams map[string]*Upstream) (*split, error) {
+	up, ok := upstreams[cfg.Pool]
+	if !ok {
+		return nil, fmt.Errorf("unknown pool %q", cfg.Pool)
+	}
+
+	b, err := balancer.New(srv.LBMethod, srv)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Sticky == "" {
+		cfg.Sticky = config.CanaryStickyIP
+	}
+	if cfg.Cookie == "" {
+		cfg.Cookie = defaultCanaryCookie
+	}
+	if cfg.Header == "" {
+		cfg.Header = defaultCanaryHeader
+	}
+
+	s := &split{
+		route:  route,
+		cfg:    cfg,
+		canary: up,
+		method: &lbMethod{name: srv.LBMethod, balancer: b},
+		intN:   rand.IntN,
+	}
+	s.weight.Store(int32(cfg.Weight))
+
+	return s, nil
+}
+
+// SetWeight меняет долю трафика в canary пул (в процентах)
+func (s *split) SetWeight(weight int) {
+	s.weight.Store(int32(weight))
+}
+
+func (s *split) Weight() int {
+	return int(s.weight.Load())
+}
+
+// useCanary решает, отправить ли запрос в canary пул. Заголовок принудительного выбора
+// имеет приоритет над весом
+func (s *split) useCanary(w http.ResponseWriter, r *http.Request) bool {
+	switch strings.ToLower(r.Header.Get(s.cfg.Header)) {
+	case canaryAlways:
+		return true
+	case canaryNever:
+		return false
+	}
+
+	return s.bucket(w, r) < s.Weight()
+}
+
+// bucket возвращает корзину клиента от 0 до 99. Клиент всегда попадает в одну и ту же корзину,
+// поэтому при увеличении веса в canary переходят новые клиенты, а уже попавшие туда остаются
+func (s *split) bucket(w http.ResponseWriter, r *http.Request) int {
+	if s.cfg.Sticky == config.CanaryStickyCookie {
+		if c, err := r.Cookie(s.cfg.Cookie); err == nil {
+			if n, err := strconv.Atoi(c.Value); err == nil && n >= 0 && n < splitBuckets {
+				return n
+			}
+		}
+
+		n := s.intN(splitBuckets)
+		http.SetCookie(w, &http.Cookie{
+			Name:     s.cfg.Cookie,
+			Value:    strconv.Itoa(n),
+			Path:     "/",
+			HttpOnly: true,
+		})
+		return n
+	}
+
+	// Имя маршрута добавляется к ключу, чтобы разные сплиты не отправляли в canary одних и тех же клиентов
+	h := fnv.New32a()
+	h.Write([]byte(s.route))
+	h.Write([]byte(netutil.ClientIP(r)))
+	return int(h.Sum32() % splitBuckets)
+}
+
+// observe учитывает завершенный запрос; ошибкой считается ответ 5xx
+func (st *splitStats) observe(status int, latency time.Duration) {
+	st.requests.Add(1)
+	if status >= http.StatusInternalServerError {
+		st.errors.Add(1)
+	}
+	st.latency.Add(int64(latency))
+}
+
+// SplitResponse - состояние canary-сплита маршрута для admin API
+type SplitResponse struct {
+	Route  string              `json:"route"`
+	Weight int                 `json:"weight"`
+	Stable SplitTargetResponse `json:"stable"`
+	Canary SplitTargetResponse `json:"canary"`
+}
+
+// SplitTargetResponse - статистика одного варианта сплита
+type SplitTargetResponse struct {
+	Pool         string  `json:"pool"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+func newSplitTargetResponse(pool string, st *splitStats) SplitTargetResponse {
+	resp := SplitTargetResponse{
+		Pool:     pool,
+		Requests: st.requests.Load(),
+		Errors:   st.errors.Load(),
+	}
+	if resp.Requests > 0 {
+		resp.AvgLatencyMs = float64(st.latency.Load()) / float64(resp.Requests) / float64(time.Millisecond)
+	}
+	return resp
+}
+
+// SetSplitRequest - запрос на изменение веса canary-сплита
+type SetSplitRequest struct {
+	Weight *int `json:"weight"`
+}
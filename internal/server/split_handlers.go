@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dielit66/cloud-camp-tt/pkg/errors"
+)
+
+// handleSplits обслуживает /api/splits:
+// GET - состояние и статистика всех canary-сплитов, PUT ?route= - изменение веса сплита маршрута
+func (lb *LoadBalancer) handleSplits(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		lb.handleListSplits(w, r)
+	case http.MethodPut:
+		lb.handleSetSplit(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (lb *LoadBalancer) handleListSplits(w http.ResponseWriter, r *http.Request) {
+	table := lb.routes.Load()
+
+	resp := make([]SplitResponse, 0)
+	for _, rt := range table.routes {
+		if rt.split != nil {
+			resp = append(resp, newSplitResponse(rt))
+		}
+	}
+
+	lb.writeJSON(w, http.StatusOK, resp)
+}
+
+func (lb *LoadBalancer) handleSetSplit(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("route")
+	if name == "" {
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "route is required"))
+		return
+	}
+
+	var req SetSplitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		lb.logger.Warn("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		})
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.Weight == nil || *req.Weight < 0 || *req.Weight > 100 {
+		writeAPIError(w, errors.NewAPIError(http.StatusBadRequest, "weight must be in [0, 100]"))
+		return
+	}
+
+	table := lb.routes.Load()
+	for _, rt := range table.routes {
+		if rt.split == nil || rt.cfg.Name != name {
+			continue
+		}
+
+		prev := rt.split.Weight()
+		rt.split.SetWeight(*req.Weight)
+
+		lb.logger.Info("Canary weight changed", map[string]interface{}{
+			"route":      name,
+			"old_weight": prev,
+			"new_weight": *req.Weight,
+		})
+
+		lb.writeJSON(w, http.StatusOK, newSplitResponse(rt))
+		return
+	}
+
+	writeAPIError(w, errors.NewAPIError(http.StatusNotFound, "split not found"))
+}
+
+func newSplitResponse(rt *route) SplitResponse {
+	return SplitResponse{
+		Route:  rt.cfg.Name,
+		Weight: rt.split.Weight(),
+		Stable: newSplitTargetResponse(rt.upstream.Name, &rt.split.stableStats),
+		Canary: newSplitTargetResponse(rt.split.canary.Name, &rt.split.canaryStats),
+	}
+}
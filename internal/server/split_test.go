@@ -0,0 +1,171 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+)
+
+// newSplitTestLoadBalancer создает балансировщик с пулами default и canary
+func newSplitTestLoadBalancer(t *testing.T, routes []config.Route) *LoadBalancer {
+	t.Helper()
+
+	upstreams := map[string]*Upstream{
+		config.DefaultPool: NewUpstream(config.DefaultPool, config.BackendPool{URLs: []string{"http://127.0.0.1:8081"}}, testLogger),
+		"canary":           NewUpstream("canary", config.BackendPool{URLs: []string{"http://127.0.0.1:8091"}}, testLogger),
+	}
+	lb := NewLoadBalancer(upstreams, testLogger, nil, nil)
+	if err := lb.SetRoutes(&config.Server{LBMethod: "RR"}, routes); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	return lb
+}
+
+// canaryRoutes возвращает маршрут web с canary-сплитом; каждый вызов создает новый конфиг, как при reload
+func canaryRoutes(canary config.Canary) []config.Route {
+	return []config.Route{{Name: "web", PathPrefix: "/", Canary: &canary}}
+}
+
+// routeSplit возвращает текущий сплит маршрута name
+func routeSplit(t *testing.T, lb *LoadBalancer, name string) *split {
+	t.Helper()
+
+	for _, rt := range lb.routes.Load().routes {
+		if rt.cfg.Name == name && rt.split != nil {
+			return rt.split
+		}
+	}
+	t.Fatalf("no split for route %q", name)
+	return nil
+}
+
+func setSplitWeight(t *testing.T, lb *LoadBalancer, route, body string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	lb.handleSplits(w, httptest.NewRequest(http.MethodPut, "/api/splits?route="+route, strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /api/splits: status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCanarySelection(t *testing.T) {
+	lb := newSplitTestLoadBalancer(t, canaryRoutes(config.Canary{Pool: "canary", Weight: 30, Sticky: config.CanaryStickyCookie}))
+	s := routeSplit(t, lb, "web")
+	// Новые клиенты получают корзины по очереди из buckets
+	buckets := []int{29, 30, 0}
+	s.intN = func(n int) int {
+		b := buckets[0]
+		buckets = buckets[1:]
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		canary bool
+		// setCookie - корзина, которую клиент должен получить в cookie (пустая - cookie не выставляется)
+		setCookie string
+	}{
+		{name: "new client below weight", canary: true, setCookie: "29"},
+		{name: "new client at weight", canary: false, setCookie: "30"},
+		{name: "sticky stable", cookie: "30", canary: false},
+		{name: "sticky canary", cookie: "5", canary: true},
+		{name: "invalid cookie", cookie: "abc", canary: true, setCookie: "0"},
+		{name: "header never", cookie: "5", header: "never", canary: false},
+		{name: "header always", cookie: "99", header: "Always", canary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "lb_canary", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Canary", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			up, _, _ := lb.routes.Load().match(r).target(w, r)
+			if got := up.Name == "canary"; got != tt.canary {
+				t.Fatalf("routed to %q, want canary = %v", up.Name, tt.canary)
+			}
+			var got string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == "lb_canary" {
+					got = c.Value
+				}
+			}
+			if got != tt.setCookie {
+				t.Fatalf("Set-Cookie bucket %q, want %q", got, tt.setCookie)
+			}
+		})
+	}
+	if len(buckets) != 0 {
+		t.Fatalf("%d buckets left unused", len(buckets))
+	}
+}
+
+func TestCanaryStickyIP(t *testing.T) {
+	for _, weight := range []int{0, 100} {
+		lb := newSplitTestLoadBalancer(t, canaryRoutes(config.Canary{Pool: "canary", Weight: weight}))
+
+		for i := 0; i < 20; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = fmt.Sprintf("10.0.0.%d:5000", i)
+			up, _, _ := lb.routes.Load().match(r).target(httptest.NewRecorder(), r)
+			if got := up.Name == "canary"; got != (weight == 100) {
+				t.Fatalf("weight %d: client %s routed to %q", weight, r.RemoteAddr, up.Name)
+			}
+		}
+	}
+
+	// Клиент попадает в одну и ту же корзину на каждом запросе
+	lb := newSplitTestLoadBalancer(t, canaryRoutes(config.Canary{Pool: "canary", Weight: 50}))
+	s := routeSplit(t, lb, "web")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	first := s.bucket(httptest.NewRecorder(), r)
+	for i := 0; i < 5; i++ {
+		if b := s.bucket(httptest.NewRecorder(), r); b != first {
+			t.Fatalf("bucket changed from %d to %d", first, b)
+		}
+	}
+}
+
+func TestSplitWeightSurvivesReload(t *testing.T) {
+	canary := config.Canary{Pool: "canary", Weight: 10}
+	lb := newSplitTestLoadBalancer(t, canaryRoutes(canary))
+	srv := &config.Server{LBMethod: "RR"}
+
+	setSplitWeight(t, lb, "web", `{"weight": 50}`)
+
+	// Reload с теми же настройками сплита и с изменениями в других маршрутах сохраняет вес из API
+	if err := lb.SetRoutes(srv, canaryRoutes(canary)); err != nil {
+		t.Fatal(err)
+	}
+	if w := routeSplit(t, lb, "web").Weight(); w != 50 {
+		t.Fatalf("weight after reload = %d, want 50", w)
+	}
+	if err := lb.SetRoutes(srv, append(canaryRoutes(canary), config.Route{Name: "api", PathPrefix: "/api"})); err != nil {
+		t.Fatal(err)
+	}
+	if w := routeSplit(t, lb, "web").Weight(); w != 50 {
+		t.Fatalf("weight after unrelated route change = %d, want 50", w)
+	}
+
+	// Изменение сплита в конфиге возвращает вес из конфига
+	canary.Weight = 20
+	if err := lb.SetRoutes(srv, canaryRoutes(canary)); err != nil {
+		t.Fatal(err)
+	}
+	if w := routeSplit(t, lb, "web").Weight(); w != 20 {
+		t.Fatalf("weight after split config change = %d, want 20", w)
+	}
+}
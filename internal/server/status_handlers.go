@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+)
+
+// StatusResponse - сводное состояние балансировщика для admin API
+type StatusResponse struct {
+	LBMethod    string            `json:"lb_method"`
+	Routes      []RouteStatus     `json:"routes"`
+	Pools       []PoolStatus      `json:"pools"`
+	RateLimiter RateLimiterStatus `json:"rate_limiter"`
+}
+
+// RouteStatus - пул и алгоритм балансировки маршрута
+type RouteStatus struct {
+	Name     string `json:"name"`
+	Pool     string `json:"pool"`
+	LBMethod string `json:"lb_method"`
+}
+
+// PoolStatus - состояние бэкендов пула
+type PoolStatus struct {
+	Name     string          `json:"name"`
+	Backends []BackendStatus `json:"backends"`
+}
+
+// BackendStatus дополняет BackendResponse результатами активных проверок и счетчиками запросов
+type BackendStatus struct {
+	backend.BackendResponse
+	// LastHealthCheck - время последней проверки; nil, если бэкенд еще не проверялся
+	LastHealthCheck     *time.Time `json:"last_health_check"`
+	LastHealthCheckOK   bool       `json:"last_health_check_ok"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Requests            int64      `json:"requests"`
+	Errors              int64      `json:"errors"`
+}
+
+// RateLimiterStatus - число активных buckets и настройки по умолчанию
+type RateLimiterStatus struct {
+	Enabled     bool               `json:"enabled"`
+	Distributed bool               `json:"distributed"`
+	Buckets     int                `json:"buckets"`
+	Default     ratelimiter.Config `json:"default"`
+}
+
+// handleStatus обслуживает GET /api/status - состояние пулов, маршрутов и rate limiter только для чтения
+func (lb *LoadBalancer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	table := lb.routes.Load()
+
+	resp := StatusResponse{
+		LBMethod: table.fallback.method.name,
+		Routes:   make([]RouteStatus, 0, len(table.routes)),
+		RateLimiter: RateLimiterStatus{
+			Enabled:     lb.rl.IsEnabled(),
+			Distributed: lb.rl.IsDistributed(),
+			Buckets:     lb.rl.BucketsCount(),
+			Default:     lb.rl.Defaults(),
+		},
+	}
+
+	for _, rt := range table.routes {
+		resp.Routes = append(resp.Routes, RouteStatus{
+			Name:     rt.cfg.Name,
+			Pool:     rt.upstream.Name,
+			LBMethod: rt.method.name,
+		})
+	}
+
+	upstreams := lb.Upstreams()
+	names := make([]string, 0, len(upstreams))
+	for name := range upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp.Pools = make([]PoolStatus, 0, len(names))
+	for _, name := range names {
+		resp.Pools = append(resp.Pools, newPoolStatus(upstreams[name]))
+	}
+
+	lb.writeJSON(w, http.StatusOK, resp)
+}
+
+func newPoolStatus(up *Upstream) PoolStatus {
+	backends := up.Pool.List()
+
+	ps := PoolStatus{
+		Name:     up.Name,
+		Backends: make([]BackendStatus, 0, len(backends)),
+	}
+	for _, b := range backends {
+		bs := BackendStatus{BackendResponse: backend.NewBackendResponse(b)}
+		bs.Requests, bs.Errors = b.Requests()
+
+		if probe, ok := up.HealthChecker.Status(b); ok {
+			bs.LastHealthCheck = &probe.LastCheck
+			bs.LastHealthCheckOK = probe.Healthy
+			bs.ConsecutiveFailures = probe.ConsecutiveFailures
+		}
+		ps.Backends = append(ps.Backends, bs)
+	}
+	return ps
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dielit66/cloud-camp-tt/internal/admin"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+)
+
+// stubRepository - репозиторий rate limiter без персональных настроек
+type stubRepository struct{}
+
+func (stubRepository) GetConfig(ctx context.Context, ip string) (ratelimiter.Config, error) {
+	return ratelimiter.Config{}, ratelimiter.ErrConfigNotFound
+}
+
+func (stubRepository) SetConfig(ctx context.Context, ip string, cfg ratelimiter.Config) error {
+	return errors.New("read-only repository")
+}
+
+func (stubRepository) DeleteConfig(ctx context.Context, ip string) error {
+	return errors.New("read-only repository")
+}
+
+func TestStatusAPI(t *testing.T) {
+	var hits atomic.Int32
+	healthy := countingBackend(t, &hits, func(w http.ResponseWriter, r *http.Request) {})
+	failing := countingBackend(t, &hits, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	pool := config.BackendPool{URLs: []string{healthy.URL, failing.URL}}
+	pool.HealthCheck.Timeout = time.Second
+	up := NewUpstream(config.DefaultPool, pool, testLogger)
+
+	rlCfg := &config.RateLimiter{Enabled: true, CleanupInterval: time.Hour, BucketExpiration: time.Hour, Algorithm: config.AlgorithmTokenBucket}
+	rlCfg.Default.MaxTokens = 10
+	rlCfg.Default.RefillRate = 5
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rl := ratelimiter.NewRateLimiter(ctx, stubRepository{}, nil, testLogger, rlCfg)
+
+	lb := NewLoadBalancer(map[string]*Upstream{config.DefaultPool: up}, testLogger, rl, stubRepository{})
+	if err := lb.SetRoutes(&config.Server{LBMethod: "RR"}, []config.Route{{Name: "api", PathPrefix: "/api", LBMethod: "LC"}}); err != nil {
+		t.Fatal(err)
+	}
+	lb.SetRetry(config.Retry{})
+	lb.SetTimeouts(config.ServerTimeouts{})
+
+	// По запросу на каждый бэкенд и одна активная проверка
+	for range pool.URLs {
+		serve(lb, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	up.HealthChecker.RunCycle(ctx, up.Pool)
+
+	auth := admin.NewAuthenticator(config.Admin{Tokens: []config.AdminToken{
+		{Name: "dashboard", Token: "read-token", Role: config.AdminRoleRead},
+	}})
+	mux := http.NewServeMux()
+	lb.registerAdmin(mux, adminGuard(auth, admin.NewAuditor("", testLogger)))
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		want   int
+	}{
+		{name: "no token", method: http.MethodGet, want: http.StatusUnauthorized},
+		{name: "read token", method: http.MethodGet, token: "read-token", want: http.StatusOK},
+		{name: "read token post", method: http.MethodPost, token: "read-token", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/status", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.Header.Set("Authorization", "Bearer read-token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	var status StatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.LBMethod != "RR" || len(status.Routes) != 1 || status.Routes[0] != (RouteStatus{Name: "api", Pool: config.DefaultPool, LBMethod: "LC"}) {
+		t.Fatalf("lb_method %q, routes %+v", status.LBMethod, status.Routes)
+	}
+	if rs := status.RateLimiter; !rs.Enabled || rs.Distributed || rs.Default != (ratelimiter.Config{MaxTokens: 10, RefillRate: 5}) {
+		t.Fatalf("rate limiter status %+v", rs)
+	}
+	if len(status.Pools) != 1 || len(status.Pools[0].Backends) != 2 {
+		t.Fatalf("pools %+v", status.Pools)
+	}
+
+	want := map[string]struct {
+		healthy          bool
+		requests, errors int64
+		failures         int
+	}{
+		healthy.URL: {healthy: true, requests: 1},
+		failing.URL: {healthy: false, requests: 1, errors: 1, failures: 1},
+	}
+	for _, bs := range status.Pools[0].Backends {
+		w := want[bs.URL]
+		if bs.LastHealthCheck == nil || bs.LastHealthCheckOK != w.healthy || bs.ConsecutiveFailures != w.failures {
+			t.Fatalf("%s: health check %v ok=%v failures=%d, want ok=%v failures=%d",
+				bs.URL, bs.LastHealthCheck, bs.LastHealthCheckOK, bs.ConsecutiveFailures, w.healthy, w.failures)
+		}
+		if bs.Requests != w.requests || bs.Errors != w.errors {
+			t.Fatalf("%s: requests %d, errors %d; want %d, %d", bs.URL, bs.Requests, bs.Errors, w.requests, w.errors)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	ratelimiter "github.com/dielit66/cloud-camp-tt/internal/rate_limiter"
+	repository "github.com/dielit66/cloud-camp-tt/internal/repository/bucket"
+	"github.com/dielit66/cloud-camp-tt/internal/tracing"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestTracePropagation проверяет трейс запроса через всю цепочку: server span продолжает входящий
+// traceparent, rate limiter, Redis и проксирование пишут дочерние span'ы, а бэкенд получает traceparent
+func TestTracePropagation(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), config.Tracing{}); err != nil {
+		t.Fatalf("tracing.Setup: %v", err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	}()
+
+	var mux sync.Mutex
+	var received http.Header
+	backendSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		received = r.Header.Clone()
+		mux.Unlock()
+	}))
+	defer backendSrv.Close()
+
+	mr := miniredis.RunT(t)
+	logger := logging.NewZeroLoggerWithWriter(3, io.Discard)
+
+	rlCfg := &config.RateLimiter{
+		Enabled:          true,
+		RateLimiterDb:    config.RateLimiterDb{Host: mr.Host(), Port: mr.Port()},
+		CleanupInterval:  time.Minute,
+		BucketExpiration: time.Hour,
+		Algorithm:        config.AlgorithmTokenBucket,
+	}
+	rlCfg.Default.MaxTokens = 10
+	rlCfg.Default.RefillRate = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := repository.NewRedisBucketSettingsRepository(logger, rlCfg)
+	rl := ratelimiter.NewRateLimiter(ctx, repo, nil, logger, rlCfg)
+
+	up := NewUpstream(config.DefaultPool, config.BackendPool{URLs: []string{backendSrv.URL}}, logger)
+	lb := NewLoadBalancer(map[string]*Upstream{config.DefaultPool: up}, logger, rl, repo)
+	srv := &config.Server{LBMethod: "RR"}
+	if err := lb.SetRoutes(srv, nil); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	lb.SetRetry(config.Retry{})
+	lb.SetTimeouts(config.ServerTimeouts{})
+
+	// Порядок middleware как в StartServer
+	handler := ratelimiter.NewRateLimiterHandler(rl, false, logger)(http.HandlerFunc(lb.ServeProxy))
+	handler = tracing.Middleware(handler)
+	handler = middleware.WithRequestID(handler)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.Header.Set("X-Request-ID", "req-7")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		if s.SpanContext.TraceID().String() != traceID {
+			t.Fatalf("span %q has trace id %s, want %s", s.Name, s.SpanContext.TraceID(), traceID)
+		}
+		spans[s.Name] = s
+	}
+
+	server, ok := spans[http.MethodGet]
+	if !ok {
+		t.Fatalf("server span not exported, got %v", spanNames(spans))
+	}
+	if v := requestIDAttr(server); v != "req-7" {
+		t.Fatalf("server span request.id = %q, want req-7", v)
+	}
+
+	parents := map[string]string{
+		"ratelimit.allow": http.MethodGet,
+		"redis GET":       "ratelimit.allow",
+		"proxy default":   http.MethodGet,
+	}
+	for name, parent := range parents {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("span %q not exported, got %v", name, spanNames(spans))
+		}
+		if s.Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Fatalf("span %q is not a child of %q", name, parent)
+		}
+	}
+
+	// Бэкенд получает traceparent span'а проксирования
+	mux.Lock()
+	got := received.Get("traceparent")
+	mux.Unlock()
+	sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(),
+		propagation.HeaderCarrier{"Traceparent": []string{got}}))
+	if sc.TraceID().String() != traceID || sc.SpanID() != spans["proxy default"].SpanContext.SpanID() {
+		t.Fatalf("backend traceparent = %q, want trace %s and proxy span %s", got, traceID, spans["proxy default"].SpanContext.SpanID())
+	}
+}
+
+func requestIDAttr(s tracetest.SpanStub) string {
+	for _, kv := range s.Attributes {
+		if kv.Key == tracing.RequestIDKey {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func spanNames(spans map[string]tracetest.SpanStub) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+
+	"github.com/dielit66/cloud-camp-tt/internal/backend"
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/internal/healthcheck"
+	"github.com/dielit66/cloud-camp-tt/pkg/logging"
+)
+
+// Upstream - именованный пул бэкендов вместе с его активными и пассивными проверками
+type Upstream struct {
+	Name          string
+	Pool          *backend.Pool
+	HealthChecker *healthcheck.HealthChecker
+	// Outlier получает результаты проксирования для пассивной проверки бэкендов
+	Outlier *healthcheck.OutlierDetector
+
+	cancel context.CancelFunc
+}
+
+func NewUpstream(name string, cfg config.BackendPool, l logging.ILogger) *Upstream {
+	pool := backend.NewPool(name, cfg, l)
+
+	return &Upstream{
+		Name:          name,
+		Pool:          pool,
+		HealthChecker: healthcheck.NewHealthChecker(cfg.HealthCheck, l),
+		Outlier:       healthcheck.NewOutlierDetector(cfg.OutlierDetection, pool, l),
+	}
+}
+
+// Start проверяет бэкенды пула, чтобы не отправлять трафик на непроверенные,
+// затем запускает периодические проверки в отдельной горутине
+func (u *Upstream) Start(ctx context.Context) {
+	ctx, u.cancel = context.WithCancel(ctx)
+
+	u.HealthChecker.RunCycle(ctx, u.Pool)
+	go u.HealthChecker.Start(ctx, u.Pool)
+}
+
+// Stop останавливает проверки пула, который больше не используется, и убирает из него бэкенды:
+// текущие запросы дорабатываются, а серии метрик бэкендов удаляются после drain
+func (u *Upstream) Stop() {
+	if u.cancel != nil {
+		u.cancel()
+	}
+	for _, b := range u.Pool.List() {
+		u.Pool.Remove(b.URL.String())
+	}
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dielit66/cloud-camp-tt/internal/config"
+	"github.com/dielit66/cloud-camp-tt/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentSpanID = "00f067aa0ba902b7"
+	testTraceparent  = "00-" + testTraceID + "-" + testParentSpanID + "-01"
+)
+
+// newTestExporter подменяет глобальный TracerProvider на провайдер с синхронным экспортом в память
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	if _, err := Setup(context.Background(), config.Tracing{}); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	})
+
+	return exporter
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	middleware.WithRequestID(Middleware(h)).ServeHTTP(w, r)
+	return w
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMiddlewareContinuesTraceparent(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	var inner trace.SpanContext
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	r.Header.Set("X-Request-ID", "req-42")
+	serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = trace.SpanContextFromContext(r.Context())
+	}), r)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+
+	if got := span.SpanContext.TraceID().String(); got != testTraceID {
+		t.Fatalf("trace id = %s, want %s from traceparent", got, testTraceID)
+	}
+	if got := span.Parent.SpanID().String(); got != testParentSpanID || !span.Parent.IsRemote() {
+		t.Fatalf("parent = %s (remote %v), want remote %s", got, span.Parent.IsRemote(), testParentSpanID)
+	}
+	if span.SpanKind != trace.SpanKindServer {
+		t.Fatalf("span kind = %v, want server", span.SpanKind)
+	}
+	if v, ok := attr(span, RequestIDKey); !ok || v.AsString() != "req-42" {
+		t.Fatalf("request.id = %q, want req-42", v.AsString())
+	}
+	// Обработчик получает контекст server span
+	if inner.SpanID() != span.SpanContext.SpanID() {
+		t.Fatal("handler context does not carry the server span")
+	}
+}
+
+func TestMiddlewareStartsNewTrace(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	w := serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if spans[0].Parent.IsValid() {
+		t.Fatal("span without traceparent has a parent")
+	}
+	// request.id генерируется WithRequestID и совпадает с заголовком ответа
+	if v, _ := attr(spans[0], RequestIDKey); v.AsString() == "" || v.AsString() != w.Header().Get("X-Request-ID") {
+		t.Fatalf("request.id = %q, want %q", v.AsString(), w.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestMiddlewareRecordsStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		code   codes.Code
+	}{
+		{status: http.StatusOK, code: codes.Unset},
+		{status: http.StatusTooManyRequests, code: codes.Unset},
+		{status: http.StatusBadGateway, code: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			exporter := newTestExporter(t)
+
+			serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			span := exporter.GetSpans()[0]
+			if v, _ := attr(span, "http.response.status_code"); v.AsInt64() != int64(tt.status) {
+				t.Fatalf("http.response.status_code = %d, want %d", v.AsInt64(), tt.status)
+			}
+			if span.Status.Code != tt.code {
+				t.Fatalf("status = %v, want %v", span.Status.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	newTestExporter(t)
+
+	ctx, span := Tracer().Start(context.Background(), "proxy")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := header.Get("traceparent"); !strings.EqualFold(got, want) {
+		t.Fatalf("traceparent = %q, want %q", got, want)
+	}
+}
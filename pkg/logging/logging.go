@@ -1,43 +1,57 @@
 package logging
 
 import (
+	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 )
 
 type Logger struct {
-	logger *zerolog.Logger
+	// logger хранится в atomic.Pointer, чтобы уровень можно было менять на лету (hot reload)
+	logger atomic.Pointer[zerolog.Logger]
 }
 
 func NewZeroLogger(level int8) *Logger {
+	return NewZeroLoggerWithWriter(level, os.Stdout)
+}
+
+// NewZeroLoggerWithWriter создает логгер, который пишет в w (например, в отдельный файл)
+func NewZeroLoggerWithWriter(level int8, w io.Writer) *Logger {
+	zl := zerolog.New(w).Level(zerologLevel(level))
 
-	var zLevel zerolog.Level
+	l := &Logger{}
+	l.logger.Store(&zl)
 
+	return l
+}
+
+// SetLevel меняет уровень логирования без пересоздания логгера
+func (l *Logger) SetLevel(level int8) {
+	zl := l.logger.Load().Level(zerologLevel(level))
+	l.logger.Store(&zl)
+}
+
+func zerologLevel(level int8) zerolog.Level {
 	switch level {
 	case 0:
-		zLevel = zerolog.DebugLevel
+		return zerolog.DebugLevel
 	case 1:
-		zLevel = zerolog.InfoLevel
+		return zerolog.InfoLevel
 	case 2:
-		zLevel = zerolog.WarnLevel
+		return zerolog.WarnLevel
 	case 3:
-		zLevel = zerolog.ErrorLevel
+		return zerolog.ErrorLevel
 	case 4:
-		zLevel = zerolog.FatalLevel
+		return zerolog.FatalLevel
 	default:
-		zLevel = zerolog.InfoLevel
-	}
-
-	zl := zerolog.New(os.Stdout).Level(zLevel)
-
-	return &Logger{
-		logger: &zl,
+		return zerolog.InfoLevel
 	}
 }
 
 func (l *Logger) Debug(msg string, fields map[string]interface{}) {
-	event := l.logger.Debug()
+	event := l.logger.Load().Debug()
 	for key, value := range fields {
 		event.Interface(key, value)
 	}
@@ -45,7 +59,7 @@ func (l *Logger) Debug(msg string, fields map[string]interface{}) {
 }
 
 func (l *Logger) Info(msg string, fields map[string]interface{}) {
-	event := l.logger.Info()
+	event := l.logger.Load().Info()
 	for key, value := range fields {
 		event.Interface(key, value)
 	}
@@ -53,7 +67,7 @@ func (l *Logger) Info(msg string, fields map[string]interface{}) {
 }
 
 func (l *Logger) Warn(msg string, fields map[string]interface{}) {
-	event := l.logger.Warn()
+	event := l.logger.Load().Warn()
 	for key, value := range fields {
 		event.Interface(key, value)
 	}
@@ -61,7 +75,7 @@ func (l *Logger) Warn(msg string, fields map[string]interface{}) {
 }
 
 func (l *Logger) Error(msg string, fields map[string]interface{}) {
-	event := l.logger.Error()
+	event := l.logger.Load().Error()
 	for key, value := range fields {
 		event.Interface(key, value)
 	}
@@ -69,7 +83,7 @@ func (l *Logger) Error(msg string, fields map[string]interface{}) {
 }
 
 func (l *Logger) Fatal(msg string, fields map[string]interface{}) {
-	event := l.logger.Fatal()
+	event := l.logger.Load().Fatal()
 	for key, value := range fields {
 		event.Interface(key, value)
 	}